@@ -5,7 +5,10 @@ import (
 	"path/filepath"
 	"testing"
 
+	"kcl-lang.io/kcl-openapi/pkg/swagger/generator"
 	"kcl-lang.io/kcl-openapi/pkg/utils"
+
+	"github.com/jessevdk/go-flags"
 )
 
 func getProjectRoot(t *testing.T) string {
@@ -31,3 +34,75 @@ func TestCRD2KCL(t *testing.T) {
 	}
 	utils.DoTestDirs(t, utils.KubeTestDirs, utils.BinaryConvertModel, true)
 }
+
+func TestStdinSpec(t *testing.T) {
+	root := getProjectRoot(t)
+	cases := map[string]struct {
+		spec   string
+		crd    bool
+		expect string
+	}{
+		"openapi": {
+			spec:   filepath.Join(root, "pkg", "swagger", "generator", "testdata", "integration", "import", "import.golden.yaml"),
+			expect: filepath.Join("models", "main", "pet.k"),
+		},
+		"crd": {
+			spec:   filepath.Join(root, "pkg", "kube_resource", "generator", "testdata", "single_model", "crontabs.golden.yaml"),
+			crd:    true,
+			expect: filepath.Join("models", "stable_example_com_v1_cron_tab.k"),
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(c.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stdin := filepath.Join(t.TempDir(), "stdin")
+			if err := os.WriteFile(stdin, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(stdin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			orig := os.Stdin
+			os.Stdin = f
+			defer func() { os.Stdin = orig }()
+
+			target := t.TempDir()
+			m := &Model{Options: options{Spec: stdinSpec, Crd: c.crd, Target: flags.Filename(target), ModelPackage: "models"}}
+			if err := m.Execute(nil); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(target, c.expect)); err != nil {
+				t.Fatalf("expect %s to be generated from the standard input: %v", c.expect, err)
+			}
+		})
+	}
+}
+
+func TestFlagAliases(t *testing.T) {
+	cases := map[string]func(opts *generator.GenOpts) bool{
+		"--prefer-title-names": func(opts *generator.GenOpts) bool { return opts.NameFromTitle },
+		"--int-format-checks":  func(opts *generator.GenOpts) bool { return opts.FormatRangeChecks },
+	}
+	for flag, isSet := range cases {
+		t.Run(flag, func(t *testing.T) {
+			var o options
+			if _, err := flags.ParseArgs(&o, nil); err != nil {
+				t.Fatal(err)
+			}
+			if isSet(o.genOpts()) {
+				t.Fatalf("expect the option of %s to be unset by default", flag)
+			}
+			if _, err := flags.ParseArgs(&o, []string{flag}); err != nil {
+				t.Fatal(err)
+			}
+			if !isSet(o.genOpts()) {
+				t.Fatalf("expect %s to set its option", flag)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package cmds
 
 import (
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 
 	crdGen "kcl-lang.io/kcl-openapi/pkg/kube_resource/generator"
 	"kcl-lang.io/kcl-openapi/pkg/swagger/generator"
@@ -15,6 +18,9 @@ import (
 
 const version = "0.5.0"
 
+// stdinSpec is the spec path reading the spec from the standard input
+const stdinSpec = "-"
+
 func init() {
 	loads.AddLoader(fmts.YAMLMatcher, fmts.YAMLDoc)
 }
@@ -37,12 +43,59 @@ type Model struct {
 }
 
 type options struct {
-	Spec                 flags.Filename `long:"spec" short:"f" description:"the path to the OpenAPI spec file. It should be a local path in your file system" group:"shared"`
-	Crd                  bool           `long:"crd" description:"if the spec file is a kubernetes CRD" group:"shared"`
-	Target               flags.Filename `long:"target" short:"t" default:"./" description:"the base directory for generating the files" group:"shared"`
-	SkipValidation       bool           `long:"skip-validation" description:"skips validation of spec prior to generation" group:"shared"`
-	ModelPackage         string         `long:"model-package" short:"m" description:"the package to save the models" default:"models"`
-	DisableKeepSpecOrder bool           `long:"disable-keep-spec-order" description:"disable to keep schema properties order identical to spec file"`
+	Spec                 flags.Filename    `long:"spec" short:"f" description:"the path to the OpenAPI spec file. It should be a local path in your file system, or - to read the spec from the standard input" group:"shared"`
+	Crd                  bool              `long:"crd" description:"if the spec file is a kubernetes CRD" group:"shared"`
+	MetadataType         string            `long:"metadata-type" description:"the KCL type of the metadata property of CRD models, e.g. pkg.ObjectMeta, defaults to the bundled ObjectMeta" value-name:"PKG.TYPE"`
+	CrdVersionPackages   bool              `long:"crd-version-packages" description:"generate the models of each version of the CRD into a subpackage named after the version, e.g. v1beta1"`
+	CrdVersion           string            `long:"crd-version" description:"generate only the named version of the CRD, e.g. v1, instead of all its versions" value-name:"VERSION"`
+	Target               flags.Filename    `long:"target" short:"t" default:"./" description:"the base directory for generating the files" group:"shared"`
+	SkipValidation       bool              `long:"skip-validation" description:"skips validation of spec prior to generation" group:"shared"`
+	ModelPackage         string            `long:"model-package" short:"m" description:"the package to save the models" default:"models"`
+	Models               []string          `long:"model" short:"M" description:"generate only the named definition along with the definitions it references, repeat for multiple, defaults to all the definitions" value-name:"NAME"`
+	DisableKeepSpecOrder bool              `long:"disable-keep-spec-order" description:"disable to keep schema properties order identical to spec file"`
+	TrimUnusedImports    bool              `long:"trim-unused-imports" description:"remove the import statements not referenced in the generated files"`
+	SourceComments       bool              `long:"source-comments" description:"annotate each generated schema with the JSON pointer of its source definition"`
+	WarnUnknownFormats   bool              `long:"warn-unknown-formats" description:"warn about the integer and number formats which are not mapped to any KCL type"`
+	Summary              bool              `long:"summary" description:"print a summary of the generation when it completes"`
+	SummaryFile          string            `long:"summary-file" description:"write the summary of the generation to a file"`
+	ExtractMixins        int               `long:"extract-mixins" description:"extract the groups of identical properties shared by at least N schemas into mixins" value-name:"N"`
+	CheckOptional        string            `long:"check-optional" description:"how the checks of optional properties handle None values: skip-none skips them, require forbids None" choice:"skip-none" choice:"require" default:"skip-none"`
+	ReadOnlyOptional     bool              `long:"read-only-optional" description:"render the read-only properties, including the fields of read-only array and map elements, as optional"`
+	DropReadOnly         bool              `long:"drop-read-only" description:"leave the read-only properties, including the fields of read-only array and map elements, out of the generated schemas"`
+	ExcludeWriteOnly     bool              `long:"exclude-write-only" description:"leave the write-only properties, such as passwords, out of the generated schemas, even when they are required"`
+	NameFromTitle        bool              `long:"name-from-title" description:"name the schemas after the title of their definition, unless x-kcl-name is set"`
+	PreferTitleNames     bool              `long:"prefer-title-names" description:"same as --name-from-title"`
+	GVKRegistry          bool              `long:"gvk-registry" description:"generate a registry mapping the apiVersion and kind of the kubernetes resources to their schemas"`
+	GenIndex             bool              `long:"gen-index" description:"generate an index module in the models package, importing all the models and exporting the ones of the subpackages"`
+	GenMod               bool              `long:"gen-mod" description:"generate a kcl.mod declaring the generated package in the target directory, depending on the k8s package when the models import it"`
+	ModName              string            `long:"mod-name" description:"the name of the package declared by the generated kcl.mod, defaults to the name of the target directory" value-name:"NAME"`
+	Force                bool              `long:"force" description:"overwrite the existing kcl.mod when generating it"`
+	Clean                bool              `long:"clean" description:"remove the KCL files of the models package before generating, so that no stale model is left behind"`
+	NoOverwrite          bool              `long:"no-overwrite" description:"fail instead of overwriting an existing file"`
+	SingleFile           bool              `long:"single-file" description:"render all the models of a package into a single file named after the package, instead of one file per model"`
+	DryRun               bool              `long:"dry-run" description:"print the path and the content of the generated files instead of writing them"`
+	Workers              int               `long:"workers" description:"the number of models rendered concurrently, defaults to the number of CPUs" value-name:"N"`
+	WithExamples         bool              `long:"with-examples" description:"render the named examples listed by the examples field of the schemas, instead of their single example"`
+	GenExamples          bool              `long:"gen-examples" description:"render the example of each definition as a commented-out instance of its schema, following the schema"`
+	FormatRangeChecks    bool              `long:"format-range-checks" description:"check the values of the sized integer formats, such as int8 or uint32, to be within the range of the format"`
+	IntFormatChecks      bool              `long:"int-format-checks" description:"same as --format-range-checks"`
+	QuantityTypes        bool              `long:"quantity-types" description:"type the kubernetes quantities, of the quantity format or named by --quantity-fields, as units.NumberMultiplier | str checked against the pattern of the quantities"`
+	QuantityFields       []string          `long:"quantity-fields" description:"the name of a property whose string or int-or-string values, or the values of its arrays and maps, are kubernetes quantities, may be repeated, e.g. cpu or limits" value-name:"NAME"`
+	DateAsStrCheck       bool              `long:"date-as-str-check" description:"check the strings of the date and date-time formats against the RFC3339 full-date and date-time patterns"`
+	FormatPatternChecks  bool              `long:"format-pattern-checks" description:"check the strings of the formats with a well-known pattern, such as uuid or email, against their pattern"`
+	NonEmptyStrings      bool              `long:"non-empty-strings" description:"check the required strings without an explicit minLength to be non-empty"`
+	StrictEnumTypes      bool              `long:"strict-enum-types" description:"fail when an enum value does not match the type of its schema, instead of warning about it"`
+	Strict               bool              `long:"strict" description:"fail when a warning is raised during the generation, such as for an unsupported construct, instead of generating models which may differ from the spec"`
+	TemplateDir          flags.Filename    `long:"template-dir" description:"the directory of the templates overriding the embedded ones of the same name, e.g. docstring.gotmpl" value-name:"DIR"`
+	Config               flags.Filename    `long:"config" description:"the YAML config file of the sections of templates to render, replacing the default ones, such as additional templates rendered for each model" value-name:"FILE"`
+	NoMangleFileSuffix   bool              `long:"no-mangle-file-suffix" description:"leave the names of the files ending with an OS, an arch or test, such as deployment_windows.k, as they are, instead of suffixing them with _swagger"`
+	NoMangleDirName      bool              `long:"no-mangle-dir-name" description:"leave the package directories named vendor or internal as they are, instead of suffixing them with _swagger"`
+	FileNaming           string            `long:"file-naming" description:"the Go template of the names of the files the models are rendered into, evaluated with the .Name and the .Package of each model, e.g. {{ camelize .Name }}.k" value-name:"TEMPLATE"`
+	AnonNaming           string            `long:"anon-naming" description:"how the schemas of the anonymous objects are named: concat joins the names of their parents, short uses their property name, hash uses a short hash of their content" choice:"concat" choice:"short" choice:"hash" default:"concat"`
+	AdditionalPropsName  string            `long:"keep-additional-props-name" description:"the suffix appended to the name of an object allowing any additional property to name the values of its additional properties" default:"AdditionalProperties" value-name:"SUFFIX"`
+	OpenAPIVersion       string            `long:"openapi-version" description:"the version of the OpenAPI spec, detected from the spec by default" choice:"2.0" choice:"3.0"`
+	RefPackage           map[string]string `long:"ref-package" description:"import the definitions of a spec file referenced by the spec from the KCL package it is generated into, instead of bundling them, e.g. common.yaml:common" value-name:"FILE:PACKAGE"`
+	UnionMapping         map[string]string `long:"union-mapping" description:"map a format or a boolean x- extension to a KCL union type, e.g. int-or-bool:int | bool" value-name:"FORMAT:TYPE"`
 }
 
 func Main() {
@@ -81,15 +134,76 @@ func Main() {
 	}
 }
 
+// genOpts converts the cli options to the options of the generator, the sections of the config file aside
+func (o *options) genOpts() *generator.GenOpts {
+	opts := new(generator.GenOpts)
+	opts.Spec = string(o.Spec)
+	opts.Target = string(o.Target)
+	opts.ValidateSpec = !o.SkipValidation
+	opts.ModelPackage = o.ModelPackage
+	opts.ModelNames = o.Models
+	opts.KeepOrder = !o.DisableKeepSpecOrder
+	opts.TrimUnusedImports = o.TrimUnusedImports
+	opts.SourceComments = o.SourceComments
+	opts.WarnUnknownFormats = o.WarnUnknownFormats
+	opts.Summary = o.Summary
+	opts.SummaryFile = o.SummaryFile
+	opts.MixinThreshold = o.ExtractMixins
+	opts.CheckOptional = o.CheckOptional
+	opts.ReadOnlyOptional = o.ReadOnlyOptional
+	opts.DropReadOnly = o.DropReadOnly
+	opts.ExcludeWriteOnly = o.ExcludeWriteOnly
+	opts.GVKRegistry = o.GVKRegistry
+	opts.GenIndex = o.GenIndex
+	opts.GenMod = o.GenMod
+	opts.ModName = o.ModName
+	opts.Force = o.Force
+	opts.SingleFile = o.SingleFile
+	opts.DryRun = o.DryRun
+	opts.Workers = o.Workers
+	opts.Clean = o.Clean
+	opts.NoOverwrite = o.NoOverwrite
+	opts.NameFromTitle = o.NameFromTitle || o.PreferTitleNames
+	opts.OpenAPIVersion = o.OpenAPIVersion
+	opts.WithExamples = o.WithExamples
+	opts.GenExamples = o.GenExamples
+	opts.FormatRangeChecks = o.FormatRangeChecks || o.IntFormatChecks
+	opts.AnonNaming = o.AnonNaming
+	opts.AdditionalPropsName = o.AdditionalPropsName
+	opts.FileNaming = o.FileNaming
+	opts.NoMangleFileSuffix = o.NoMangleFileSuffix
+	opts.NoMangleDirName = o.NoMangleDirName
+	opts.TemplateDir = string(o.TemplateDir)
+	opts.QuantityTypes = o.QuantityTypes
+	opts.QuantityFields = o.QuantityFields
+	opts.DateAsStrCheck = o.DateAsStrCheck
+	opts.FormatPatternChecks = o.FormatPatternChecks
+	opts.NonEmptyStrings = o.NonEmptyStrings
+	opts.StrictEnumTypes = o.StrictEnumTypes
+	opts.Strict = o.Strict
+	opts.RefPackages = o.RefPackage
+	opts.UnionMapping = o.UnionMapping
+	return opts
+}
+
 // Execute generates a model file
 func (m *Model) Execute(args []string) error {
-	opts := new(generator.GenOpts)
-	// cli opts to generator.GenOpts
-	opts.Spec = string(m.Options.Spec)
-	opts.Target = string(m.Options.Target)
-	opts.ValidateSpec = !m.Options.SkipValidation
-	opts.ModelPackage = m.Options.ModelPackage
-	opts.KeepOrder = !m.Options.DisableKeepSpecOrder
+	opts := m.Options.genOpts()
+	if opts.Spec == stdinSpec {
+		spec, err := bufferSpec(os.Stdin)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(spec)
+		opts.Spec = spec
+	}
+	if m.Options.Config != "" {
+		sections, err := generator.ReadConfig(string(m.Options.Config))
+		if err != nil {
+			return err
+		}
+		opts.Sections = sections
+	}
 
 	// set default configurations
 	if err := opts.EnsureDefaults(); err != nil {
@@ -99,11 +213,16 @@ func (m *Model) Execute(args []string) error {
 	// when the spec is a crd, get openapi spec file from it
 	if m.Options.Crd {
 		spec, err := crdGen.GetSpec(&crdGen.GenOpts{
-			Spec: opts.Spec,
+			Spec:            opts.Spec,
+			MetadataType:    m.Options.MetadataType,
+			VersionPackages: m.Options.CrdVersionPackages,
+			Version:         m.Options.CrdVersion,
 		})
 		if err != nil {
 			return err
 		}
+		// the spec generated from the crd is written to a temporary directory of its own
+		defer os.RemoveAll(filepath.Dir(spec))
 		opts.Spec = spec
 		// do not run validate spec on spec file generated from crd
 		opts.ValidateSpec = false
@@ -118,3 +237,18 @@ func (m *Model) Execute(args []string) error {
 	log.Printf("Generation completed!")
 	return nil
 }
+
+// bufferSpec writes the spec read from r, such as the standard input, to a temporary file and returns its path,
+// so that both the OpenAPI spec and the CRD are loaded from a file. The file is YAML, which JSON is a subset of.
+func bufferSpec(r io.Reader) (string, error) {
+	tmpFile, err := os.CreateTemp("", "kcl-openapi-stdin-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	defer tmpFile.Close()
+	if _, err := io.Copy(tmpFile, r); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("could not read the spec from the standard input: %v", err)
+	}
+	return tmpFile.Name(), nil
+}
@@ -23,6 +23,8 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/go-openapi/swag"
+	"gopkg.in/yaml.v2"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/install"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -40,6 +42,8 @@ import (
 const (
 	k8sSpecFile         = "api_spec/k8s/k8s.json"
 	objectMetaSchemaRef = "k8s.json#/definitions/k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"
+	xKclPrinterColumn   = "x-kcl-printer-column"
+	xKubernetesGVK      = "x-kubernetes-group-version-kind"
 )
 
 var (
@@ -52,6 +56,8 @@ func init() {
 	install.Install(scheme.Scheme)
 }
 
+// GetSpec generates the openapi spec of the crds of the spec file and returns the path of the temporary file
+// it is written to, in a temporary directory of its own which the caller removes once done with it.
 func GetSpec(opts *GenOpts) (string, error) {
 	// read crd content from file
 	path, err := filepath.Abs(opts.Spec)
@@ -62,30 +68,18 @@ func GetSpec(opts *GenOpts) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("could not load spec: %s, err: %s", opts.Spec, err)
 	}
-	// generate openapi spec from crd
-	swagger, err := generate(string(crdContent))
+	// generate openapi spec from the crds
+	swagger, err := generateAll(string(crdContent), opts)
 	if err != nil {
 		return "", fmt.Errorf("could not generate swagger spec: %s, err: %s", opts.Spec, err)
 	}
 	// write openapi spec to tmp file, along with the referenced k8s.json
-	swaggerContent, err := json.MarshalIndent(swagger, "", "")
+	specPath, err := writeSpec(swagger)
 	if err != nil {
-		return "", fmt.Errorf("could not validate swagger spec: %s, err: %s", opts.Spec, err)
-	}
-	tmpSpecDir := os.TempDir()
-	tmpFile, err := os.CreateTemp(tmpSpecDir, "kcl-swagger-")
-	if err != nil {
-		return "", fmt.Errorf("could not validate swagger spec: %s, err: %s", opts.Spec, err)
-	}
-	// copy k8s.json to tmpDir
-	if err := os.WriteFile(filepath.Join(tmpSpecDir, "k8s.json"), []byte(k8sFile), 0644); err != nil {
-		return "", fmt.Errorf("could not generate swagger spec file: %s, err: %s", opts.Spec, err)
-	}
-	if _, err := tmpFile.Write(swaggerContent); err != nil {
 		return "", fmt.Errorf("could not generate swagger spec file: %s, err: %s", opts.Spec, err)
 	}
 	// return the tmp openapi spec file path
-	return tmpFile.Name(), nil
+	return specPath, nil
 }
 
 // GetSpecs retrieves specifications from the given GenOpts and returns a list of temporary file paths for the generated OpenAPI specs.
@@ -93,7 +87,7 @@ func GetSpec(opts *GenOpts) (string, error) {
 // Parameters:
 // - opts: a GenOpts struct that contains the options and parameters required for generating the specs
 // Returns:
-// - []string: a list of temporary file paths for the generated OpenAPI specs
+// - []string: a list of temporary file paths for the generated OpenAPI specs, each in a temporary directory of its own
 // - error: an error message if any error occurs.
 func GetSpecs(opts *GenOpts) ([]string, error) {
 	var result []string
@@ -112,33 +106,44 @@ func GetSpecs(opts *GenOpts) ([]string, error) {
 	}
 	for _, content := range contents {
 		// generate openapi spec from crd
-		swagger, err := generate(content)
+		swagger, err := generate(content, opts)
 		if err != nil {
 			return result, fmt.Errorf("could not generate swagger spec: %s, err: %s", opts.Spec, err)
 		}
 		// write openapi spec to tmp file, along with the referenced k8s.json
-		swaggerContent, err := json.MarshalIndent(swagger, "", "")
+		specPath, err := writeSpec(swagger)
 		if err != nil {
-			return result, fmt.Errorf("could not validate swagger spec: %s, err: %s", opts.Spec, err)
-		}
-		tmpSpecDir := os.TempDir()
-		tmpFile, err := os.CreateTemp(tmpSpecDir, "kcl-swagger-")
-		if err != nil {
-			return result, fmt.Errorf("could not validate swagger spec: %s, err: %s", opts.Spec, err)
-		}
-		// copy k8s.json to tmpDir
-		if err := os.WriteFile(filepath.Join(tmpSpecDir, "k8s.json"), []byte(k8sFile), 0644); err != nil {
-			return result, fmt.Errorf("could not generate swagger spec file: %s, err: %s", opts.Spec, err)
-		}
-		if _, err := tmpFile.Write(swaggerContent); err != nil {
 			return result, fmt.Errorf("could not generate swagger spec file: %s, err: %s", opts.Spec, err)
 		}
 		// Append the tmp openapi spec file path
-		result = append(result, tmpFile.Name())
+		result = append(result, specPath)
 	}
 	return result, nil
 }
 
+// writeSpec writes the openapi spec generated from a crd to a file of a temporary directory of its own, along with
+// the k8s.json it refers to, and returns the path of the file. The caller removes the directory once done with it.
+func writeSpec(swagger *spec.Swagger) (string, error) {
+	swaggerContent, err := marshalSwagger(swagger)
+	if err != nil {
+		return "", err
+	}
+	tmpSpecDir, err := os.MkdirTemp("", "kcl-swagger-")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(tmpSpecDir, "k8s.json"), []byte(k8sFile), 0644); err != nil {
+		os.RemoveAll(tmpSpecDir)
+		return "", err
+	}
+	specPath := filepath.Join(tmpSpecDir, "swagger.yaml")
+	if err := os.WriteFile(specPath, swaggerContent, 0644); err != nil {
+		os.RemoveAll(tmpSpecDir)
+		return "", err
+	}
+	return specPath, nil
+}
+
 // splitDocuments returns a slice of all documents contained in a YAML string. Multiple documents can be divided by the
 // YAML document separator (---). It allows for white space and comments to be after the separator on the same line,
 // but will return an error if anything else is on the line.
@@ -168,8 +173,56 @@ func splitDocuments(s string) ([]string, error) {
 	return docs, nil
 }
 
+// generateAll generates the swagger models of all the crds of a YAML stream, whose documents are separated by ---.
+// The definitions of the crds are merged into a single spec, a definition generated by several crds being an error.
+func generateAll(crdYaml string, opts *GenOpts) (*spec.Swagger, error) {
+	docs, err := splitDocuments(crdYaml)
+	if err != nil {
+		return nil, err
+	}
+	var swagger *spec.Swagger
+	// the index of the document each definition is generated from
+	sources := make(map[string]int)
+	for i, doc := range docs {
+		if isBlankDocument(doc) {
+			continue
+		}
+		docSwagger, err := generate(doc, opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not generate the crd of document %d: %v", i+1, err)
+		}
+		if swagger == nil {
+			swagger = docSwagger
+		} else {
+			for name, def := range docSwagger.Definitions {
+				if _, ok := swagger.Definitions[name]; ok {
+					return nil, fmt.Errorf("the definition %s is generated by both the crds of documents %d and %d", name, sources[name]+1, i+1)
+				}
+				swagger.Definitions[name] = def
+			}
+		}
+		for name := range docSwagger.Definitions {
+			sources[name] = i
+		}
+	}
+	if swagger == nil {
+		return nil, errors.New("no crd found")
+	}
+	return swagger, nil
+}
+
+// isBlankDocument tells whether a YAML document only contains white space and comments
+func isBlankDocument(doc string) bool {
+	for _, line := range strings.Split(doc, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
 // generate swagger model based on crd
-func generate(crdYaml string) (*spec.Swagger, error) {
+func generate(crdYaml string, opts *GenOpts) (*spec.Swagger, error) {
 	crdObj, _, err := scheme.Codecs.UniversalDeserializer().
 		Decode([]byte(crdYaml), nil, nil)
 	if err != nil {
@@ -179,7 +232,7 @@ func generate(crdYaml string) (*spec.Swagger, error) {
 	if err != nil {
 		return nil, err
 	}
-	return buildSwagger(crd)
+	return buildSwagger(crd, opts)
 }
 
 func crdObj2CrdInternal(crdObj runtime.Object) (*apiextensions.CustomResourceDefinition, error) {
@@ -225,9 +278,14 @@ func CRDContainsValidation(crd *apiextensions.CustomResourceDefinition) bool {
 	return false
 }
 
-func buildSwagger(crd *apiextensions.CustomResourceDefinition) (*spec.Swagger, error) {
+func buildSwagger(crd *apiextensions.CustomResourceDefinition, opts *GenOpts) (*spec.Swagger, error) {
 	var schemas spec.Definitions = map[string]spec.Schema{}
 	group, kind := crd.Spec.Group, crd.Spec.Names.Kind
+	if opts.Version != "" {
+		if err := selectVersion(crd, opts.Version); err != nil {
+			return nil, err
+		}
+	}
 	if crd.Spec.Validation != nil && crd.Spec.Validation.OpenAPIV3Schema != nil {
 		var schema spec.Schema
 		err := validation.ConvertJSONSchemaProps(crd.Spec.Validation.OpenAPIV3Schema, &schema)
@@ -235,12 +293,16 @@ func buildSwagger(crd *apiextensions.CustomResourceDefinition) (*spec.Swagger, e
 			return nil, err
 		}
 		var version string
-		if len(crd.Spec.Versions) >= 0 {
+		if len(crd.Spec.Versions) > 0 {
 			version = crd.Spec.Versions[0].Name
 		} else {
 			version = crd.Spec.Version
 		}
-		setKubeNative(&schema, group, version, kind)
+		setKubeNative(&schema, group, version, kind, opts.MetadataType)
+		setPrinterColumns(&schema, crd.Spec.AdditionalPrinterColumns)
+		if opts.VersionPackages {
+			setVersionPackage(&schema, group, version, kind)
+		}
 		name := fmt.Sprintf("%s.%s.%s", group, version, kind)
 		schemas[name] = schema
 	} else if len(crd.Spec.Versions) > 0 {
@@ -251,8 +313,17 @@ func buildSwagger(crd *apiextensions.CustomResourceDefinition) (*spec.Swagger, e
 				if err != nil {
 					return nil, err
 				}
+				columns := version.AdditionalPrinterColumns
+				if len(columns) == 0 {
+					// the printer columns shared by all the versions are set on the crd spec
+					columns = crd.Spec.AdditionalPrinterColumns
+				}
 				version := version.Name
-				setKubeNative(&schema, group, version, kind)
+				setKubeNative(&schema, group, version, kind, opts.MetadataType)
+				setPrinterColumns(&schema, columns)
+				if opts.VersionPackages {
+					setVersionPackage(&schema, group, version, kind)
+				}
 				name := fmt.Sprintf("%s.%s.%s", group, version, kind)
 				schemas[name] = schema
 			}
@@ -275,21 +346,163 @@ func buildSwagger(crd *apiextensions.CustomResourceDefinition) (*spec.Swagger, e
 	}, nil
 }
 
-func setKubeNative(schema *spec.Schema, group string, version string, kind string) {
-	// set kube kind, version, group
+// selectVersion keeps the version named name as the only version of the crd. The schema of the version
+// takes precedence over the schema shared by all the versions, which defaults to the schema of the first version.
+func selectVersion(crd *apiextensions.CustomResourceDefinition, name string) error {
+	for _, version := range crd.Spec.Versions {
+		if version.Name != name {
+			continue
+		}
+		crd.Spec.Versions = []apiextensions.CustomResourceDefinitionVersion{version}
+		if version.Schema != nil && version.Schema.OpenAPIV3Schema != nil {
+			crd.Spec.Validation = nil
+		}
+		return nil
+	}
+	if len(crd.Spec.Versions) == 0 && crd.Spec.Version == name {
+		return nil
+	}
+	return fmt.Errorf("the version %s is not found in the crd %s", name, crd.Name)
+}
+
+func setKubeNative(schema *spec.Schema, group string, version string, kind string, metadataType string) {
+	// set kube kind, version, group, which are required read-only constants: pinned by a single-valued enum
+	// and defaulted to their value, the same way the const keyword is
+	apiVersion := fmt.Sprintf("%s/%s", group, version)
 	apiVersionSchema := spec.Schema{}
 	apiVersionSchema.ReadOnly = true
 	apiVersionSchema.Typed("string", "")
-	apiVersionSchema.WithDefault(fmt.Sprintf("%s/%s", group, version))
+	apiVersionSchema.WithEnum(apiVersion)
+	apiVersionSchema.WithDefault(apiVersion)
 	apiVersionSchema.WithDescription(swaggerTypeMetadataDescriptions["apiVersion"])
 	kindSchema := spec.Schema{}
 	kindSchema.ReadOnly = true
 	kindSchema.Typed("string", "")
+	kindSchema.WithEnum(kind)
 	kindSchema.WithDefault(kind)
 	kindSchema.WithDescription(swaggerTypeMetadataDescriptions["kind"])
 	schema.SetProperty("apiVersion", apiVersionSchema)
 	schema.SetProperty("kind", kindSchema)
-	schema.SetProperty("metadata", *spec.RefSchema(objectMetaSchemaRef).
+	for _, name := range []string{"apiVersion", "kind"} {
+		if !swag.ContainsStrings(schema.Required, name) {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	schema.SetProperty("metadata", *metadataSchema(metadataType).
 		WithDescription(swaggerPartialObjectMetadataDescriptions["metadata"]))
+	// mark the schema with its group, version and kind the same way the kubernetes spec does
+	schema.AddExtension(xKubernetesGVK, []interface{}{
+		map[string]interface{}{
+			"group":   group,
+			"version": version,
+			"kind":    kind,
+		},
+	})
 	// todo: update more k8s refs to kcl format
 }
+
+// setVersionPackage generates the schema into the subpackage named after its version, with the x-kcl-type
+// extension: the stable.example.com/v1 CronTab is generated as the CronTab schema of v1/stable_example_com_cron_tab.k.
+// The module is named after the group so that the kinds of the same version from different groups don't collide.
+func setVersionPackage(schema *spec.Schema, group string, version string, kind string) {
+	module := swag.ToFileName(group + "." + kind)
+	schema.AddExtension("x-kcl-type", map[string]interface{}{
+		"type": kind,
+		"import": map[string]interface{}{
+			"package": version + "." + module,
+			"alias":   module,
+		},
+	})
+}
+
+// marshalSwagger marshals the swagger spec into YAML. The properties of the definitions are listed in alphabetical
+// order, as the CRD schemas don't keep the order of their properties, except for the kubernetes native ones, which
+// come first so that the generated schemas start with them.
+func marshalSwagger(swagger *spec.Swagger) ([]byte, error) {
+	data, err := json.Marshal(swagger)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for _, item := range doc {
+		if item.Key != "definitions" {
+			continue
+		}
+		defs, _ := item.Value.(yaml.MapSlice)
+		for _, def := range defs {
+			fields, _ := def.Value.(yaml.MapSlice)
+			for i, field := range fields {
+				if props, ok := field.Value.(yaml.MapSlice); ok && field.Key == "properties" {
+					fields[i].Value = kubeNativeFirst(props)
+				}
+			}
+		}
+	}
+	return yaml.Marshal(doc)
+}
+
+// kubeNativeFirst moves the apiVersion, kind and metadata properties set by setKubeNative before the other ones
+func kubeNativeFirst(props yaml.MapSlice) yaml.MapSlice {
+	ordered := make(yaml.MapSlice, 0, len(props))
+	for _, name := range []string{"apiVersion", "kind", "metadata"} {
+		for _, prop := range props {
+			if prop.Key == name {
+				ordered = append(ordered, prop)
+			}
+		}
+	}
+	for _, prop := range props {
+		if prop.Key != "apiVersion" && prop.Key != "kind" && prop.Key != "metadata" {
+			ordered = append(ordered, prop)
+		}
+	}
+	return ordered
+}
+
+// setPrinterColumns marks the properties displayed by "kubectl get" with the x-kcl-printer-column extension,
+// holding the column name and priority. The columns whose JSON path doesn't lead to an inline property of the
+// schema, such as the ones of the metadata, are skipped.
+func setPrinterColumns(schema *spec.Schema, columns []apiextensions.CustomResourceColumnDefinition) {
+	for _, column := range columns {
+		setPrinterColumn(schema, strings.Split(strings.TrimPrefix(column.JSONPath, "."), "."), column)
+	}
+}
+
+func setPrinterColumn(schema *spec.Schema, path []string, column apiextensions.CustomResourceColumnDefinition) {
+	prop, ok := schema.Properties[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) > 1 {
+		setPrinterColumn(&prop, path[1:], column)
+	} else {
+		prop.AddExtension(xKclPrinterColumn, map[string]interface{}{
+			"name":     column.Name,
+			"priority": column.Priority,
+		})
+	}
+	schema.Properties[path[0]] = prop
+}
+
+// metadataSchema returns the schema of the metadata property. By default, it refers to the bundled ObjectMeta.
+// When a user-provided type such as "pkg.ObjectMeta" is given, the property reuses that type via x-kcl-type
+// and the package is imported.
+func metadataSchema(metadataType string) *spec.Schema {
+	if metadataType == "" {
+		return spec.RefSchema(objectMetaSchemaRef)
+	}
+	kclType := map[string]interface{}{
+		"type": metadataType[strings.LastIndex(metadataType, ".")+1:],
+	}
+	if strings.Contains(metadataType, ".") {
+		kclType["import"] = map[string]interface{}{
+			"package": metadataType,
+		}
+	}
+	schema := new(spec.Schema).Typed("object", "")
+	schema.AddExtension("x-kcl-type", kclType)
+	return schema
+}
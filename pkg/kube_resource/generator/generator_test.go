@@ -3,6 +3,8 @@ package generator
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"k8s.io/client-go/kubernetes/scheme"
@@ -612,7 +614,7 @@ func TestCrdObj2CrdInternal(t *testing.T) {
 }
 
 func TestGenerate(t *testing.T) {
-	swagger, err := generate(workload)
+	swagger, err := generate(workload, &GenOpts{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -630,3 +632,169 @@ func TestSplitDocuments(t *testing.T) {
 		t.Errorf("splitDocuments failed. expected 3, got %d", len(files))
 	}
 }
+
+func TestGenerateAll(t *testing.T) {
+	swagger, err := generateAll(workload+v1Crd, &GenOpts{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	for _, name := range []string{"core.oam.dev.v1alpha2.ContainerizedWorkload", "stable.example.com.v1.CronTab"} {
+		if _, ok := swagger.Definitions[name]; !ok {
+			t.Errorf("generateAll failed. expected the definition %s", name)
+		}
+	}
+
+	_, err = generateAll(v1Crd+v1beta1Crd, &GenOpts{})
+	if err == nil || err.Error() != "the definition stable.example.com.v1.CronTab is generated by both the crds of documents 2 and 3" {
+		t.Errorf("generateAll failed. unexpected error: %v", err)
+	}
+
+	if _, err = generateAll("\n---\n# no crd\n", &GenOpts{}); err == nil || err.Error() != "no crd found" {
+		t.Errorf("generateAll failed. unexpected error: %v", err)
+	}
+}
+
+func TestVersionPackages(t *testing.T) {
+	swagger, err := generateAll(v1Crd, &GenOpts{VersionPackages: true})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	schema := swagger.Definitions["stable.example.com.v1.CronTab"]
+	expected := map[string]interface{}{
+		"type": "CronTab",
+		"import": map[string]interface{}{
+			"package": "v1.stable_example_com_cron_tab",
+			"alias":   "stable_example_com_cron_tab",
+		},
+	}
+	if !reflect.DeepEqual(schema.Extensions["x-kcl-type"], expected) {
+		t.Errorf("VersionPackages failed. expected x-kcl-type %v, got %v", expected, schema.Extensions["x-kcl-type"])
+	}
+	metadata := schema.Properties["metadata"]
+	if ref := metadata.Ref.String(); ref != objectMetaSchemaRef {
+		t.Errorf("VersionPackages failed. expected the metadata to refer to %s, got %s", objectMetaSchemaRef, ref)
+	}
+
+	swagger, err = generateAll(v1Crd, &GenOpts{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if _, ok := swagger.Definitions["stable.example.com.v1.CronTab"].Extensions["x-kcl-type"]; ok {
+		t.Errorf("VersionPackages failed. unexpected x-kcl-type without the option")
+	}
+}
+
+func TestValidationRules(t *testing.T) {
+	swagger, err := generate(`
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: scalers.example.com
+spec:
+  group: example.com
+  names:
+    kind: Scaler
+    plural: scalers
+  scope: Namespaced
+  versions:
+    - name: v1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              x-kubernetes-validations:
+                - rule: self.minReplicas <= self.maxReplicas
+                  message: minReplicas must not exceed maxReplicas
+              properties:
+                minReplicas:
+                  type: integer
+                maxReplicas:
+                  type: integer
+`, &GenOpts{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	data, err := marshalSwagger(swagger)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	for _, expected := range []string{
+		"x-kubernetes-validations:\n",
+		"- rule: self.minReplicas <= self.maxReplicas\n",
+		"message: minReplicas must not exceed maxReplicas\n",
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("the validation rules are not kept, expected %q in:\n%s", expected, data)
+		}
+	}
+}
+
+func TestSelectVersion(t *testing.T) {
+	crd := `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: crontabs.stable.example.com
+spec:
+  group: stable.example.com
+  versions:
+    - name: v1beta1
+      served: true
+      storage: false
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            cronSpec:
+              type: string
+    - name: v1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: string
+  scope: Namespaced
+  names:
+    plural: crontabs
+    kind: CronTab
+`
+	swagger, err := generate(crd, &GenOpts{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(swagger.Definitions) != 2 {
+		t.Errorf("selectVersion failed. expected all the versions, got %d definitions", len(swagger.Definitions))
+	}
+
+	swagger, err = generate(crd, &GenOpts{Version: "v1beta1"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	schema, ok := swagger.Definitions["stable.example.com.v1beta1.CronTab"]
+	if len(swagger.Definitions) != 1 || !ok {
+		t.Fatalf("selectVersion failed. expected only the v1beta1 definition, got %d definitions", len(swagger.Definitions))
+	}
+	if _, ok := schema.Properties["cronSpec"]; !ok {
+		t.Errorf("selectVersion failed. expected the schema of the v1beta1 version")
+	}
+
+	// the validation shared by all the versions
+	swagger, err = generate(v1beta1Crd, &GenOpts{Version: "v1"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if _, ok := swagger.Definitions["stable.example.com.v1.CronTab"]; len(swagger.Definitions) != 1 || !ok {
+		t.Errorf("selectVersion failed. expected only the v1 definition, got %d definitions", len(swagger.Definitions))
+	}
+
+	if _, err = generate(crd, &GenOpts{Version: "v2"}); err == nil || err.Error() != "the version v2 is not found in the crd crontabs.stable.example.com" {
+		t.Errorf("selectVersion failed. unexpected error: %v", err)
+	}
+}
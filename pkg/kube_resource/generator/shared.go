@@ -4,4 +4,10 @@ package generator
 type GenOpts struct {
 	// the spec file path
 	Spec string
+	// the KCL type of the metadata property, such as "pkg.ObjectMeta", defaults to the bundled ObjectMeta
+	MetadataType string
+	// place the models of each version of the CRDs into a subpackage named after the version, such as "v1beta1"
+	VersionPackages bool
+	// the only version of the CRDs to generate, all the versions are generated when it is empty
+	Version string
 }
@@ -0,0 +1,106 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+
+	crdGen "kcl-lang.io/kcl-openapi/pkg/kube_resource/generator"
+)
+
+// Config configures the generation of the KCL models of a spec by GenerateModels.
+// Its zero value, but the spec, matches the defaults of the kcl-openapi command.
+type Config struct {
+	// Spec is the path of the OpenAPI spec file, or of the kubernetes CRD file when CRD is set
+	Spec string
+	// CRD tells the spec file is a kubernetes CRD, whose models are generated along with the ObjectMeta they refer to
+	CRD bool
+	// CRDMetadataType is the KCL type of the metadata property of the CRD models, such as "pkg.ObjectMeta",
+	// the bundled ObjectMeta by default
+	CRDMetadataType string
+	// CRDVersion is the only version of the CRD to generate, all the versions are generated when it is empty
+	CRDVersion string
+	// CRDVersionPackages generates the models of each version of the CRD into a subpackage named after the version
+	CRDVersionPackages bool
+	// ModelPackage is the package the models are generated into, "models" by default
+	ModelPackage string
+	// SkipValidation skips the validation of the spec before the generation
+	SkipValidation bool
+	// DisableKeepOrder sorts the properties of the schemas by name, instead of keeping the order of the spec
+	DisableKeepOrder bool
+	// Options tunes the generation further, e.g. Options.GenIndex generates the index of the models package.
+	// Its Spec, Target, ModelPackage, ValidateSpec, KeepOrder and DryRun fields are set from the config.
+	Options *GenOpts
+}
+
+// Result is the result of the generation of GenerateModels
+type Result struct {
+	// Files maps the path of each generated file, relative to the target directory, e.g. "models/pet.k",
+	// to its content
+	Files map[string][]byte
+	// Warnings are the warnings raised during the generation, such as the constructs of the spec which are not
+	// supported, in the order they are raised
+	Warnings []Warning
+}
+
+// GenerateModels generates the KCL models of a spec in memory, nothing being written to the disk, so that the
+// generator can be embedded in other tools. The generation stops with the error of the context when it is done.
+//
+//	result, err := generator.GenerateModels(ctx, generator.Config{Spec: "petstore.yaml"})
+//	if err != nil {
+//		return err
+//	}
+//	pet := result.Files["models/pet.k"]
+func GenerateModels(ctx context.Context, cfg Config) (Result, error) {
+	opts := new(GenOpts)
+	if cfg.Options != nil {
+		*opts = *cfg.Options
+	}
+	opts.Spec = cfg.Spec
+	opts.Target = ""
+	opts.ModelPackage = cfg.ModelPackage
+	if opts.ModelPackage == "" {
+		opts.ModelPackage = "models"
+	}
+	opts.ValidateSpec = !cfg.SkipValidation
+	opts.KeepOrder = !cfg.DisableKeepOrder
+	opts.DryRun = false
+	opts.files = &generatedFiles{files: make(map[string][]byte)}
+	if err := opts.EnsureDefaults(); err != nil {
+		return Result{}, err
+	}
+
+	if cfg.CRD {
+		spec, err := crdGen.GetSpec(&crdGen.GenOpts{
+			Spec:            opts.Spec,
+			MetadataType:    cfg.CRDMetadataType,
+			VersionPackages: cfg.CRDVersionPackages,
+			Version:         cfg.CRDVersion,
+		})
+		if err != nil {
+			return Result{}, err
+		}
+		// the spec generated from the CRD is written to a temporary directory of its own
+		defer os.RemoveAll(filepath.Dir(spec))
+		opts.Spec = spec
+		// the spec generated from the CRD is not validated
+		opts.ValidateSpec = false
+	}
+	if err := generate(ctx, opts); err != nil {
+		return Result{}, err
+	}
+	return Result{Files: opts.files.files, Warnings: opts.Warnings}, nil
+}
+
+// generatedFiles collects the generated files in memory, keyed by their slash-separated path
+type generatedFiles struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (f *generatedFiles) add(path string, content []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[filepath.ToSlash(path)] = content
+}
@@ -0,0 +1,515 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-openapi/spec"
+)
+
+// GenRule is a CEL validation rule of a kubernetes structural schema, set by the x-kubernetes-validations extension.
+// The rule is rendered as a check of the KCL schema when it can be translated, else as a commented-out check.
+type GenRule struct {
+	Rule    string
+	Message string
+	// Location is the location of self relative to the schema rendering the rule, such as "replicas" or
+	// "ports[*]", empty for the schema itself. It is only set by UntranslatedRules.
+	Location string
+	// fields are the properties of the schema the rule is set on, the fields of self the rule may select,
+	// nil when the schema declares no property
+	fields map[string]bool
+}
+
+// Expr returns the KCL expression the rule is translated into, self being the value of subject, or the schema
+// itself when subject is empty. It returns an empty string when the rule can't be translated.
+func (r GenRule) Expr(subject string) string {
+	expr, err := translateCEL(r.Rule, subject, r.fields)
+	if err != nil {
+		debugLog("the CEL rule %q is not translated: %v", r.Rule, err)
+		return ""
+	}
+	return expr
+}
+
+// Translatable tells if the rule can be translated into a KCL expression
+func (r GenRule) Translatable() bool {
+	_, err := translateCEL(r.Rule, "self", r.fields)
+	return err == nil
+}
+
+// UntranslatedRules returns the rules of the schema and of its properties, including the ones of their items and
+// values, which can't be translated. They are rendered as commented-out checks, on a single line.
+func (g GenSchema) UntranslatedRules() []GenRule {
+	var rules []GenRule
+	var collect func(sch *GenSchema, location string)
+	collect = func(sch *GenSchema, location string) {
+		for _, rule := range sch.Rules {
+			if !rule.Translatable() {
+				rule.Rule = strings.Join(strings.Fields(rule.Rule), " ")
+				rule.Location = location
+				rules = append(rules, rule)
+			}
+		}
+		if location == "" {
+			return
+		}
+		if sch.Items != nil {
+			collect(sch.Items, location+"[*]")
+		}
+		if sch.AdditionalProperties != nil {
+			collect(sch.AdditionalProperties, location+"[*]")
+		}
+	}
+	collect(&g, "")
+	for i := range g.Properties {
+		collect(&g.Properties[i], g.Properties[i].EscapedName)
+	}
+	return rules
+}
+
+// hasTranslatableRules tells if any of the rules can be translated into a check
+func hasTranslatableRules(rules []GenRule) bool {
+	for _, rule := range rules {
+		if rule.Translatable() {
+			return true
+		}
+	}
+	return false
+}
+
+// kubernetesValidations returns the CEL rules of a schema set by the x-kubernetes-validations extension.
+// The message of a rule defaults to the one reported by kubernetes.
+func kubernetesValidations(sch *spec.Schema) []GenRule {
+	values, _ := sch.Extensions[k8sValidations].([]interface{})
+	var fields map[string]bool
+	if len(sch.Properties) > 0 {
+		fields = make(map[string]bool, len(sch.Properties))
+		for name := range sch.Properties {
+			fields[name] = true
+		}
+	}
+	var rules []GenRule
+	for _, value := range values {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		rule, _ := m["rule"].(string)
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		message, _ := m["message"].(string)
+		if message == "" {
+			message = "failed rule: " + rule
+		}
+		rules = append(rules, GenRule{Rule: rule, Message: message, fields: fields})
+	}
+	return rules
+}
+
+// the precedences of the KCL expressions the CEL expressions are translated into,
+// an operand of lower precedence than its operator is parenthesized
+const (
+	precCond = iota
+	precOr
+	precAnd
+	precNot
+	precRel
+	precAdd
+	precMul
+	precUnary
+	precPrimary
+)
+
+// celExpr is a translated expression along with its precedence
+type celExpr struct {
+	code string
+	prec int
+}
+
+// wrap parenthesizes the expression when its precedence is lower than prec
+func (e celExpr) wrap(prec int) string {
+	if e.prec < prec {
+		return "(" + e.code + ")"
+	}
+	return e.code
+}
+
+// celParser translates a subset of CEL into KCL: the literals, the fields of self, the logical, relational and
+// arithmetic operators, the conditional operator, has(), size() and the string methods startsWith(), endsWith()
+// and contains(). The macros, such as all() or exists(), and the transition rules referring to oldSelf are not
+// translated.
+type celParser struct {
+	tokens  []string
+	pos     int
+	subject string
+	fields  map[string]bool
+}
+
+// translateCEL translates a CEL rule into a KCL expression, self being the value of subject, or the schema itself
+// when subject is empty. The fields of self are restricted to the given ones, unless they are nil, so that the rule
+// doesn't refer to an attribute which is not defined.
+func translateCEL(rule, subject string, fields map[string]bool) (string, error) {
+	tokens, err := tokenizeCEL(rule)
+	if err != nil {
+		return "", err
+	}
+	p := &celParser{tokens: tokens, subject: subject, fields: fields}
+	expr, err := p.conditional()
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.tokens) {
+		return "", fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return expr.code, nil
+}
+
+// tokenizeCEL splits a CEL expression into its tokens, the strings being converted into KCL strings
+func tokenizeCEL(rule string) ([]string, error) {
+	var tokens []string
+	runes := []rune(rule)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+					switch runes[j] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					case '\\', '\'', '"':
+						sb.WriteRune(runes[j])
+					default:
+						return nil, fmt.Errorf("unsupported escape sequence \\%c", runes[j])
+					}
+					continue
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, strconv.Quote(sb.String()))
+			i = j + 1
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == 'e' || runes[j] == 'E') {
+				j++
+			}
+			number := string(runes[i:j])
+			// the unsigned integers, such as 1u, are plain integers in KCL
+			if j < len(runes) && (runes[j] == 'u' || runes[j] == 'U') {
+				j++
+			}
+			tokens = append(tokens, number)
+			i = j
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(runes) && (runes[j] == '_' || unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			if i+1 < len(runes) {
+				switch op := string(runes[i : i+2]); op {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					tokens = append(tokens, op)
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("<>!+-*/%?:.,()[]", r) {
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+			tokens = append(tokens, string(r))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func (p *celParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *celParser) accept(token string) bool {
+	if p.peek() == token {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *celParser) expect(token string) error {
+	if !p.accept(token) {
+		return fmt.Errorf("expected %q instead of %q", token, p.peek())
+	}
+	return nil
+}
+
+// conditional translates c ? a : b into a if c else b
+func (p *celParser) conditional() (celExpr, error) {
+	cond, err := p.binary(precOr)
+	if err != nil || !p.accept("?") {
+		return cond, err
+	}
+	then, err := p.conditional()
+	if err != nil {
+		return celExpr{}, err
+	}
+	if err := p.expect(":"); err != nil {
+		return celExpr{}, err
+	}
+	otherwise, err := p.conditional()
+	if err != nil {
+		return celExpr{}, err
+	}
+	return celExpr{code: fmt.Sprintf("%s if %s else %s", then.wrap(precOr), cond.wrap(precOr), otherwise.wrap(precCond)), prec: precCond}, nil
+}
+
+// celBinaryOperators maps the binary operators of each precedence level to their KCL operators
+var celBinaryOperators = map[int]map[string]string{
+	precOr:  {"||": "or"},
+	precAnd: {"&&": "and"},
+	precRel: {"==": "==", "!=": "!=", "<": "<", "<=": "<=", ">": ">", ">=": ">=", "in": "in"},
+	precAdd: {"+": "+", "-": "-"},
+	precMul: {"*": "*", "/": "/", "%": "%"},
+}
+
+// binary translates the left-associative binary operations of the precedence level prec
+func (p *celParser) binary(prec int) (celExpr, error) {
+	next := func() (celExpr, error) {
+		switch prec {
+		case precOr:
+			return p.binary(precAnd)
+		case precAnd:
+			return p.binary(precRel)
+		case precRel:
+			return p.binary(precAdd)
+		case precAdd:
+			return p.binary(precMul)
+		default:
+			return p.unary()
+		}
+	}
+	left, err := next()
+	if err != nil {
+		return celExpr{}, err
+	}
+	for {
+		op, ok := celBinaryOperators[prec][p.peek()]
+		if !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := next()
+		if err != nil {
+			return celExpr{}, err
+		}
+		// the relational operators don't chain, unlike in KCL
+		leftPrec := prec
+		if prec == precRel {
+			leftPrec = precAdd
+		}
+		left = celExpr{code: fmt.Sprintf("%s %s %s", left.wrap(leftPrec), op, right.wrap(prec+1)), prec: prec}
+	}
+}
+
+// unary translates the logical negation and the arithmetic negation
+func (p *celParser) unary() (celExpr, error) {
+	if p.accept("!") {
+		operand, err := p.unary()
+		if err != nil {
+			return celExpr{}, err
+		}
+		// the negation binds tighter in CEL than in KCL
+		return celExpr{code: "not " + operand.wrap(precNot), prec: precNot}, nil
+	}
+	if p.accept("-") {
+		operand, err := p.unary()
+		if err != nil {
+			return celExpr{}, err
+		}
+		return celExpr{code: "-" + operand.wrap(precUnary), prec: precUnary}, nil
+	}
+	return p.member()
+}
+
+// member translates the field selections, the indexes and the method calls
+func (p *celParser) member() (celExpr, error) {
+	start := p.pos
+	expr, err := p.primary()
+	if err != nil {
+		return celExpr{}, err
+	}
+	// the fields selected on self are the properties of the schema
+	selectsSelf := p.pos == start+1 && p.tokens[start] == "self"
+	for {
+		switch {
+		case p.accept("."):
+			name := p.peek()
+			if !isCELIdent(name) {
+				return celExpr{}, fmt.Errorf("expected a field name instead of %q", name)
+			}
+			p.pos++
+			if !p.accept("(") {
+				if selectsSelf && p.fields != nil && !p.fields[name] {
+					return celExpr{}, fmt.Errorf("the field %s is not a property of the schema", name)
+				}
+				selectsSelf = false
+				field := DefaultLanguageFunc().ManglePropertyName(name)
+				if expr.code == "" {
+					// a field of the schema itself
+					expr = celExpr{code: field, prec: precPrimary}
+				} else {
+					expr = celExpr{code: expr.code + "." + field, prec: precPrimary}
+				}
+				continue
+			}
+			if expr.code == "" {
+				return celExpr{}, fmt.Errorf("self can't be translated when it is the schema itself")
+			}
+			args, err := p.arguments()
+			if err != nil {
+				return celExpr{}, err
+			}
+			if expr, err = celMethod(expr, name, args); err != nil {
+				return celExpr{}, err
+			}
+			selectsSelf = false
+		case p.accept("["):
+			if expr.code == "" {
+				return celExpr{}, fmt.Errorf("self can't be translated when it is the schema itself")
+			}
+			index, err := p.conditional()
+			if err != nil {
+				return celExpr{}, err
+			}
+			if err := p.expect("]"); err != nil {
+				return celExpr{}, err
+			}
+			expr = celExpr{code: fmt.Sprintf("%s[%s]", expr.code, index.code), prec: precPrimary}
+			selectsSelf = false
+		default:
+			if expr.code == "" {
+				return celExpr{}, fmt.Errorf("self can't be translated when it is the schema itself")
+			}
+			return expr, nil
+		}
+	}
+}
+
+// celMethod translates the call of a method on the receiver
+func celMethod(receiver celExpr, name string, args []celExpr) (celExpr, error) {
+	switch {
+	case name == "size" && len(args) == 0:
+		return celExpr{code: fmt.Sprintf("len(%s)", receiver.code), prec: precPrimary}, nil
+	case name == "startsWith" && len(args) == 1:
+		return celExpr{code: fmt.Sprintf("%s.startswith(%s)", receiver.wrap(precPrimary), args[0].code), prec: precPrimary}, nil
+	case name == "endsWith" && len(args) == 1:
+		return celExpr{code: fmt.Sprintf("%s.endswith(%s)", receiver.wrap(precPrimary), args[0].code), prec: precPrimary}, nil
+	case name == "contains" && len(args) == 1:
+		return celExpr{code: fmt.Sprintf("%s in %s", args[0].wrap(precAdd), receiver.wrap(precAdd)), prec: precRel}, nil
+	}
+	return celExpr{}, fmt.Errorf("the method %s with %d arguments is not supported", name, len(args))
+}
+
+// primary translates the literals, self, the lists, the parenthesized expressions and the function calls.
+// self is translated into an empty expression when it is the schema itself, only its fields being selectable.
+func (p *celParser) primary() (celExpr, error) {
+	token := p.peek()
+	p.pos++
+	switch {
+	case token == "":
+		return celExpr{}, fmt.Errorf("unexpected end of the rule")
+	case token == "(":
+		expr, err := p.conditional()
+		if err != nil {
+			return celExpr{}, err
+		}
+		if err := p.expect(")"); err != nil {
+			return celExpr{}, err
+		}
+		return celExpr{code: "(" + expr.code + ")", prec: precPrimary}, nil
+	case token == "[":
+		var items []string
+		for !p.accept("]") {
+			if len(items) > 0 {
+				if err := p.expect(","); err != nil {
+					return celExpr{}, err
+				}
+			}
+			item, err := p.conditional()
+			if err != nil {
+				return celExpr{}, err
+			}
+			items = append(items, item.code)
+		}
+		return celExpr{code: "[" + strings.Join(items, ", ") + "]", prec: precPrimary}, nil
+	case strings.HasPrefix(token, `"`) || unicode.IsDigit(rune(token[0])):
+		return celExpr{code: token, prec: precPrimary}, nil
+	case token == "true":
+		return celExpr{code: "True", prec: precPrimary}, nil
+	case token == "false":
+		return celExpr{code: "False", prec: precPrimary}, nil
+	case token == "null":
+		return celExpr{code: "None", prec: precPrimary}, nil
+	case token == "self":
+		return celExpr{code: p.subject, prec: precPrimary}, nil
+	case isCELIdent(token) && p.accept("("):
+		args, err := p.arguments()
+		if err != nil {
+			return celExpr{}, err
+		}
+		return celFunction(token, args)
+	}
+	return celExpr{}, fmt.Errorf("%q is not supported", token)
+}
+
+// celFunction translates the call of a global function
+func celFunction(name string, args []celExpr) (celExpr, error) {
+	switch {
+	case name == "size" && len(args) == 1 && args[0].code != "":
+		return celExpr{code: fmt.Sprintf("len(%s)", args[0].code), prec: precPrimary}, nil
+	case name == "has" && len(args) == 1 && args[0].code != "":
+		return celExpr{code: fmt.Sprintf("%s not in [None, Undefined]", args[0].wrap(precAdd)), prec: precRel}, nil
+	}
+	return celExpr{}, fmt.Errorf("the function %s with %d arguments is not supported", name, len(args))
+}
+
+// arguments translates the arguments of a call, the opening parenthesis being consumed
+func (p *celParser) arguments() ([]celExpr, error) {
+	var args []celExpr
+	for !p.accept(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.conditional()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// isCELIdent tells if the token is an identifier
+func isCELIdent(token string) bool {
+	return token != "" && (token[0] == '_' || unicode.IsLetter(rune(token[0])))
+}
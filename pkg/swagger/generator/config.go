@@ -0,0 +1,102 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v2"
+)
+
+// ReadConfig reads the sections of templates rendered by the generator from a YAML config file, e.g.
+//
+//	models:
+//	- name: definition
+//	  source: asset:model
+//	  target: "{{ joinFilePath .Target (toFilePath .Package) }}"
+//	  file_name: "{{ (snakize (pascalize (.Name))) }}.k"
+//	- name: doc
+//	  source: templates/doc.gotmpl
+//	  target: "{{ joinFilePath .Target \"docs\" }}"
+//	  file_name: "{{ .Name }}.md"
+//	  skip_format: true
+//
+// The sections of the config replace the default ones, so the default model template has to be listed along with
+// the additional ones. The sources are either embedded templates, prefixed with "asset:", or template files, relative
+// to the directory of the config file. The file names are kept as rendered by the templates.
+func ReadConfig(path string) (SectionOpts, error) {
+	var sections SectionOpts
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return sections, fmt.Errorf("could not read the config file %s: %v", path, err)
+	}
+	var raw interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return sections, fmt.Errorf("could not parse the config file %s: %v", path, err)
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{ErrorUnused: true, Result: &sections})
+	if err != nil {
+		return sections, err
+	}
+	if err := decoder.Decode(raw); err != nil {
+		return sections, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	dir := filepath.Dir(path)
+	for _, templ := range sections.templates() {
+		if templ.Source != "" && !isAsset(templ.Source) && !filepath.IsAbs(templ.Source) {
+			templ.Source = filepath.Join(dir, templ.Source)
+		}
+		templ.keepFileName = true
+	}
+	return sections, nil
+}
+
+// templates returns all the templates of the sections
+func (s *SectionOpts) templates() []*TemplateOpts {
+	var templates []*TemplateOpts
+	for i := range s.Models {
+		templates = append(templates, &s.Models[i])
+	}
+	return append(templates, &s.Index, &s.Mod)
+}
+
+// isAsset tells if the source of a template is an embedded template
+func isAsset(source string) bool {
+	return strings.HasPrefix(strings.ToLower(source), "asset:")
+}
+
+// checkTemplateFiles checks the template files of the sections to exist
+func (g *GenOpts) checkTemplateFiles() error {
+	for _, templ := range g.Sections.templates() {
+		if templ.Source == "" || isAsset(templ.Source) {
+			continue
+		}
+		info, err := os.Stat(templ.Source)
+		if err != nil {
+			return fmt.Errorf("the template %s of the section %s can't be loaded: %v", templ.Source, templ.Name, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("the template %s of the section %s is a directory", templ.Source, templ.Name)
+		}
+	}
+	return nil
+}
+
+// loadTemplateFiles loads the template files of the sections into the repository of the templates
+func (g *GenOpts) loadTemplateFiles() error {
+	for _, templ := range g.Sections.templates() {
+		if templ.Source == "" || isAsset(templ.Source) {
+			continue
+		}
+		content, err := os.ReadFile(templ.Source)
+		if err != nil {
+			return fmt.Errorf("the template %s of the section %s can't be loaded: %v", templ.Source, templ.Name, err)
+		}
+		if err := g.templates.AddFile(templ.Source, string(content)); err != nil {
+			return fmt.Errorf("the template %s of the section %s can't be loaded: %v", templ.Source, templ.Name, err)
+		}
+	}
+	return nil
+}
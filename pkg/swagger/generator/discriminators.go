@@ -15,6 +15,9 @@
 package generator
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/go-openapi/analysis"
 	"github.com/go-openapi/spec"
 	"github.com/go-openapi/swag"
@@ -45,13 +48,9 @@ func discriminatorInfo(doc *analysis.Spec) *discInfo {
 	baseTypes := make(map[string]discor)
 	for _, sch := range doc.AllDefinitions() {
 		if sch.Schema.Discriminator != "" {
-			tpe, _ := sch.Schema.Extensions.GetString(xKclName)
-			if tpe == "" {
-				tpe = swag.ToGoName(sch.Name)
-			}
 			baseTypes[sch.Ref.String()] = discor{
 				FieldName: sch.Schema.Discriminator,
-				KclType:   tpe,
+				KclType:   discriminatedType(sch.Name, *sch.Schema),
 				JSONName:  sch.Name,
 			}
 		}
@@ -66,19 +65,18 @@ func discriminatorInfo(doc *analysis.Spec) *discInfo {
 					if name == "" {
 						name = sch.Name
 					}
-					tpe, _ := sch.Schema.Extensions.GetString(xKclName)
-					if tpe == "" {
-						tpe = swag.ToGoName(sch.Name)
-					}
 					dce := discee{
 						FieldName:  bt.FieldName,
 						FieldValue: name,
 						Ref:        sch.Ref,
 						ParentRef:  ao.Ref,
 						JSONName:   sch.Name,
-						KclType:    tpe,
+						KclType:    discriminatedType(sch.Name, *sch.Schema),
+					}
+					// a subtype of several base types inherits the first one only
+					if _, ok := subTypes[sch.Ref.String()]; !ok {
+						subTypes[sch.Ref.String()] = dce
 					}
-					subTypes[sch.Ref.String()] = dce
 					bt.Children = append(bt.Children, dce)
 					baseTypes[ao.Ref.String()] = bt
 				}
@@ -87,3 +85,118 @@ func discriminatorInfo(doc *analysis.Spec) *discInfo {
 	}
 	return &discInfo{Discriminators: baseTypes, Discriminated: subTypes}
 }
+
+// discriminatedType returns the kcl type of a definition of a discriminated hierarchy. A definition reusing
+// the type of another package with the x-kcl-type extension is qualified with the full path of the package,
+// so that the base type and its subtypes can be told apart across packages. The types are not imported:
+// the subtypes already import their base type, and kcl rejects cyclic imports.
+func discriminatedType(name string, schema spec.Schema) string {
+	if tpe, _ := schema.Extensions.GetString(xKclName); tpe != "" {
+		return tpe
+	}
+	if _, ok := schema.Extensions[xKclType]; ok {
+		tpe, pkg, _, _ := knownDefKclType(name, schema, nil)
+		if pkg == "" {
+			return tpe
+		}
+		return pkg + "." + tpe
+	}
+	return swag.ToGoName(name)
+}
+
+// liftChildDiscriminators moves the discriminators declared by a child in the inline branch
+// of its allOf composition to the base type the composition refers to, which is where the
+// polymorphic generation expects them.
+func liftChildDiscriminators(sw *spec.Swagger, warnings *warningRecorder) {
+	for name, sch := range sw.Definitions {
+		var baseRef string
+		bases := 0
+		for _, ao := range sch.AllOf {
+			if ref := ao.Ref.String(); ref != "" {
+				baseRef = ref
+				bases++
+			}
+		}
+		for i, ao := range sch.AllOf {
+			if ao.Discriminator == "" || ao.Ref.String() != "" {
+				continue
+			}
+			if bases != 1 || !strings.HasPrefix(baseRef, "#/definitions/") {
+				warnings.warn(name, "the discriminator %s declared in the allOf branch of %s doesn't refer to a single base type, skipped", ao.Discriminator, name)
+				continue
+			}
+			baseName := strings.TrimPrefix(baseRef, "#/definitions/")
+			base, ok := sw.Definitions[baseName]
+			if !ok {
+				continue
+			}
+			if base.Discriminator != "" && base.Discriminator != ao.Discriminator {
+				warnings.warn(name, "the discriminator %s declared in the allOf branch of %s conflicts with the discriminator %s of %s, skipped", ao.Discriminator, name, base.Discriminator, baseName)
+				continue
+			}
+			debugLog("lifting the discriminator %s declared by %s to %s", ao.Discriminator, name, baseName)
+			base.Discriminator = ao.Discriminator
+			sw.Definitions[baseName] = base
+			sch.AllOf[i].Discriminator = ""
+		}
+	}
+}
+
+// checkInheritanceCycles reports the definitions which inherit from themselves, through the $ref of their
+// allOf branches or their own $ref, such as A allOf B and B allOf A, with the path of the cycle.
+func checkInheritanceCycles(sw *spec.Swagger) error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(sw.Definitions))
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for i, p := range path {
+				if p == name {
+					start = i
+				}
+			}
+			return fmt.Errorf("cyclic allOf inheritance: %s", strings.Join(append(path[start:], name), " -> "))
+		}
+		sch, ok := sw.Definitions[name]
+		if !ok {
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, parent := range inheritedDefinitions(&sch) {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range sortedDefinitionNames(sw) {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inheritedDefinitions returns the local definitions a schema inherits from, through its $ref or the ones of its
+// allOf branches, including the branches nested in the inline ones
+func inheritedDefinitions(sch *spec.Schema) []string {
+	var parents []string
+	if ref := sch.Ref.String(); strings.HasPrefix(ref, "#/definitions/") {
+		parents = append(parents, strings.TrimPrefix(ref, "#/definitions/"))
+	}
+	for i := range sch.AllOf {
+		parents = append(parents, inheritedDefinitions(&sch.AllOf[i])...)
+	}
+	return parents
+}
@@ -1,5 +1,12 @@
 package generator
 
+import (
+	"math"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
 // typeMapping contains a mapping of type name to kcl type
 var typeMapping = map[string]string{
 	// Standard formats with native, straightforward, mapping
@@ -7,10 +14,37 @@ var typeMapping = map[string]string{
 	boolean: "bool",
 	integer: "int",
 	number:  "float",
-	// TODO: OpenAPI spec does not support multi-types.
-	//  k8s.json set type=string & format=int-or-str to support int | str multi-type.
-	//  hack specially for int-or-string.
-	intOrStr: "int | str",
+}
+
+// unionMapping contains the default mapping of a format or a boolean vendor extension to
+// a kcl union type.
+//
+// OpenAPI spec does not support multi-types: k8s.json sets type=string & format=int-or-string,
+// or the x-kubernetes-int-or-string flag, to describe an int | str multi-type.
+// More mappings may be configured with GenOpts.UnionMapping.
+var unionMapping = map[string]string{
+	intOrStr:        "int | str",
+	k8sIntOrStrFlag: "int | str",
+}
+
+// newUnionMapping merges the configured mappings of formats or vendor extensions to kcl union
+// types with the default ones. Format keys are normalized the same way formats are resolved.
+func newUnionMapping(custom map[string]string) map[string]string {
+	mapping := make(map[string]string, len(unionMapping)+len(custom))
+	for k, v := range unionMapping {
+		mapping[k] = v
+	}
+	for k, v := range custom {
+		mapping[unionMappingKey(k)] = v
+	}
+	return mapping
+}
+
+func unionMappingKey(key string) string {
+	if strings.HasPrefix(strings.ToLower(key), "x-") {
+		return strings.ToLower(key)
+	}
+	return strings.Replace(key, "-", "", -1)
 }
 
 // formatMapping contains a type-specific version of mapping of format to kcl type
@@ -30,6 +64,73 @@ var formatMapping = map[string]map[string]string{
 	},
 }
 
+// dateFormatPatterns contains the anchored patterns of the date formats, keyed by their normalized name:
+// the full-date and the date-time of RFC3339, which the date strings are checked against when
+// GenOpts.DateAsStrCheck is set.
+var dateFormatPatterns = map[string]string{
+	"date":     `^\d{4}-\d{2}-\d{2}$`,
+	"datetime": `^\d{4}-\d{2}-\d{2}[Tt]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[+-]\d{2}:\d{2})$`,
+}
+
+// formatPatterns contains the anchored patterns of the string formats, keyed by their normalized name,
+// which the strings are checked against when GenOpts.FormatPatternChecks is set.
+var formatPatterns = map[string]string{
+	"uuid":         `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`,
+	"bsonobjectid": `^[0-9a-fA-F]{24}$`,
+	"email":        `^[^@\s]+@[^@\s]+\.[^@\s]+$`,
+}
+
+// formatRange is the range of the values of a sized integer format
+type formatRange struct {
+	min, max float64
+	// hasMax is false when the upper bound isn't exactly representable by a float64 bound
+	hasMax bool
+}
+
+// integerFormatRanges contains the ranges of the sized integer formats, which the 64-bit kcl int doesn't
+// enforce. int64 is left out since it is the range of the kcl int itself, and uint and uint64 only get a
+// lower bound, since their upper bound is out of the range of the kcl int.
+var integerFormatRanges = map[string]formatRange{
+	"int8":   {min: math.MinInt8, max: math.MaxInt8, hasMax: true},
+	"int16":  {min: math.MinInt16, max: math.MaxInt16, hasMax: true},
+	"int32":  {min: math.MinInt32, max: math.MaxInt32, hasMax: true},
+	"uint":   {min: 0},
+	"uint8":  {min: 0, max: math.MaxUint8, hasMax: true},
+	"uint16": {min: 0, max: math.MaxUint16, hasMax: true},
+	"uint32": {min: 0, max: math.MaxUint32, hasMax: true},
+	"uint64": {min: 0},
+}
+
+// quantityPattern is the anchored pattern of the serialized kubernetes quantities, such as "500m" or "2Gi",
+// which the quantities are checked against when GenOpts.QuantityTypes is set.
+const quantityPattern = `^(\+|-)?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))(([KMGTPE]i)|[numkMGTPE]|([eE](\+|-)?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))))?$`
+
+// quantityType returns the kcl type of a kubernetes quantity: a number multiplier of the units module, such as
+// 2Gi, or its string form, as well as an int when the quantity is an x-kubernetes-int-or-string value.
+func quantityType(schema *spec.Schema) string {
+	if isIntOrStr, ok := schema.Extensions.GetBool(k8sIntOrStrFlag); (isIntOrStr && ok) || schema.Type.Contains(integer) {
+		return "int | units.NumberMultiplier | str"
+	}
+	return "units.NumberMultiplier | str"
+}
+
+// knownNumberFormats contains the formats of integer and number types which silently fall back
+// to the base kcl type, other formats are reported when GenOpts.WarnUnknownFormats is set
+var knownNumberFormats = map[string]struct{}{
+	"int":    {},
+	"int8":   {},
+	"int16":  {},
+	"int32":  {},
+	"int64":  {},
+	"uint":   {},
+	"uint8":  {},
+	"uint16": {},
+	"uint32": {},
+	"uint64": {},
+	"float":  {},
+	"double": {},
+}
+
 // kcl primitive types
 var primitives = map[string]struct{}{
 	"bool":   {},
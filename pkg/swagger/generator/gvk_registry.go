@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// gvkRegistryName is the name of the generated registry, which is also the name of its file
+const gvkRegistryName = "gvk_registry"
+
+// GenGVKRegistry contains the information needed to generate the registry mapping the apiVersion
+// and kind of the kubernetes resources to the generated schemas
+type GenGVKRegistry struct {
+	GenCommon
+	Name        string
+	Package     string
+	Module      string
+	Imports     []importStmt
+	APIVersions []GenAPIVersion
+}
+
+// GenAPIVersion lists the kinds of an apiVersion in the registry
+type GenAPIVersion struct {
+	APIVersion string
+	Kinds      []GenKind
+}
+
+// GenKind maps a kind to the schema generated for it, e.g. "apps.v1.deployment.Deployment",
+// which is the path of the schema module within the models package followed by the schema name
+type GenKind struct {
+	Kind   string
+	Schema string
+}
+
+// renderGVKRegistry generates the registry of the models marked with their group, version and kind
+// by the x-kubernetes-group-version-kind extension, such as the models of the CRDs and of the kubernetes spec.
+func (a *generator) renderGVKRegistry(models GenDefinitions) error {
+	templ := TemplateOpts{
+		Name:     "gvk-registry",
+		Source:   "asset:gvkregistry",
+		Target:   "{{ joinFilePath .Target (toFilePath .Package) }}",
+		FileName: "{{ .Name }}.k",
+	}
+	registry := GenGVKRegistry{
+		GenCommon: GenCommon{Copyright: a.GenOpts.Copyright},
+		Name:      gvkRegistryName,
+		Package:   a.ModelsPackage,
+	}
+	root, _, err := a.GenOpts.location(&templ, &registry)
+	if err != nil {
+		return err
+	}
+
+	schemas := make(map[string]map[string]string)
+	for i := range models {
+		mod := &models[i]
+		for _, gvk := range groupVersionKinds(a.Models[mod.OriginalName].Extensions[k8sGVK]) {
+			apiVersion := gvk["version"]
+			if gvk["group"] != "" {
+				apiVersion = gvk["group"] + "/" + apiVersion
+			}
+			kind := gvk["kind"]
+			if apiVersion == "" || kind == "" {
+				continue
+			}
+			schema, err := a.schemaPath(root, mod)
+			if err != nil {
+				return err
+			}
+			if schemas[apiVersion] == nil {
+				schemas[apiVersion] = make(map[string]string)
+			}
+			if existing, ok := schemas[apiVersion][kind]; ok {
+				a.GenOpts.warnings.warn(mod.Name, "the kind %s of %s is generated both as %s and %s, only %s is registered", kind, apiVersion, existing, schema, existing)
+				continue
+			}
+			schemas[apiVersion][kind] = schema
+		}
+	}
+	if len(schemas) == 0 {
+		a.GenOpts.warnings.warn("", "no model is marked with its group, version and kind by the %s extension, the registry is empty", k8sGVK)
+	}
+
+	for apiVersion, kinds := range schemas {
+		version := GenAPIVersion{APIVersion: apiVersion}
+		for kind, schema := range kinds {
+			version.Kinds = append(version.Kinds, GenKind{Kind: kind, Schema: schema})
+		}
+		sort.Slice(version.Kinds, func(i, j int) bool { return version.Kinds[i].Kind < version.Kinds[j].Kind })
+		registry.APIVersions = append(registry.APIVersions, version)
+	}
+	sort.Slice(registry.APIVersions, func(i, j int) bool {
+		return registry.APIVersions[i].APIVersion < registry.APIVersions[j].APIVersion
+	})
+	return a.GenOpts.write(&templ, &registry)
+}
+
+// schemaPath returns the path of the schema generated for the model, relative to the root of the models package
+func (a *generator) schemaPath(root string, mod *GenDefinition) (string, error) {
+	dir, file, err := a.modelLocation(mod)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return "", err
+	}
+	name := mod.KclType[strings.LastIndex(mod.KclType, ".")+1:]
+	parts := []string{strings.TrimSuffix(file, filepath.Ext(file)), name}
+	if rel != "." {
+		parts = append(strings.Split(filepath.ToSlash(rel), "/"), parts...)
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// groupVersionKinds reads the value of the x-kubernetes-group-version-kind extension,
+// which is either a list of group, version and kind objects or a single one.
+func groupVersionKinds(ext interface{}) []map[string]string {
+	var values []interface{}
+	switch v := ext.(type) {
+	case []interface{}:
+		values = v
+	case map[string]interface{}:
+		values = []interface{}{v}
+	}
+	var gvks []map[string]string
+	for _, value := range values {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		gvk := make(map[string]string, len(m))
+		for k, v := range m {
+			if s, ok := v.(string); ok {
+				gvk[k] = s
+			}
+		}
+		gvks = append(gvks, gvk)
+	}
+	return gvks
+}
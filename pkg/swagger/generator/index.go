@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// indexName is the name of the generated index, which is also the name of its file
+const indexName = "index"
+
+// GenIndex contains the information needed to generate the index of the models package,
+// which imports the subpackages of the models and exports their schemas from the models package
+type GenIndex struct {
+	GenCommon
+	Name                 string
+	Package              string
+	Pkg                  string
+	Module               string
+	Imports              []importStmt
+	HasPatternValidation bool
+	// Aliases are the schemas of the subpackages, exported under a name of the models package
+	Aliases []GenIndexAlias
+	// Schemas are the names of all the schemas exported by the models package
+	Schemas []string
+}
+
+// GenIndexAlias exports a schema of a subpackage, e.g. V1CronTab for v1.CronTab
+type GenIndexAlias struct {
+	Name string
+	Type string
+}
+
+// renderIndex generates the index of the models package. The subpackages are imported the way the models of the
+// root package import them, and their schemas are aliased after the name they are imported as, e.g. V1CronTab.
+// The schemas of the root package need no alias, they are listed along with the aliases.
+func (a *generator) renderIndex(models GenDefinitions) error {
+	templ := a.GenOpts.Sections.Index
+	index := GenIndex{
+		GenCommon: GenCommon{Copyright: a.GenOpts.Copyright},
+		Name:      indexName,
+		Package:   a.ModelsPackage,
+	}
+	root, file, err := a.GenOpts.location(&templ, &index)
+	if err != nil {
+		return err
+	}
+
+	imports := make(map[string]importStmt)
+	names := make(map[string]string)
+	for i := range models {
+		mod := &models[i]
+		if mod.Pkg != "" {
+			continue
+		}
+		dir, modFile, err := a.modelLocation(mod)
+		if err != nil {
+			return err
+		}
+		if filepath.Join(dir, modFile) == filepath.Join(root, file) {
+			return fmt.Errorf("the index %s conflicts with the module of the model %s, it can't be generated", filepath.Join(root, file), mod.Name)
+		}
+		names[mod.KclType] = mod.KclType
+		index.Schemas = append(index.Schemas, mod.KclType)
+	}
+	for i := range models {
+		mod := &models[i]
+		if mod.Pkg == "" {
+			continue
+		}
+		sch := GenSchema{resolvedType: resolvedType{KclType: mod.KclType, Pkg: mod.Pkg, Module: mod.Module}}
+		collectTypeImport(&sch, "", imports)
+		alias := pascalize(imports[mod.Pkg].AsName) + mod.KclType
+		if existing, ok := names[alias]; ok {
+			a.GenOpts.warnings.warn(mod.Name, "the schema %s can't be exported as %s by the index, which already exports %s", sch.KclType, alias, existing)
+			continue
+		}
+		names[alias] = sch.KclType
+		index.Aliases = append(index.Aliases, GenIndexAlias{Name: alias, Type: sch.KclType})
+		index.Schemas = append(index.Schemas, alias)
+	}
+	index.Imports = sortImports(imports)
+	return a.GenOpts.write(&templ, &index)
+}
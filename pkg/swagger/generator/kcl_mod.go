@@ -0,0 +1,56 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// k8sModVersion is the version of the k8s package the generated kcl.mod depends on,
+// when the models import the kubernetes models, such as the ObjectMeta of the CRDs
+const k8sModVersion = "1.28"
+
+// GenKclMod contains the information needed to generate the kcl.mod declaring the generated package
+type GenKclMod struct {
+	Name         string
+	Dependencies []GenModDependency
+}
+
+// GenModDependency is a package the generated package depends on
+type GenModDependency struct {
+	Name    string
+	Version string
+}
+
+// renderKclMod generates the kcl.mod of the generated package in the target directory. The package is named
+// after the target directory, unless the name is set by the options. It depends on the k8s package when
+// a model imports it. An existing kcl.mod is kept, unless it is forced to be overwritten.
+func (a *generator) renderKclMod(models GenDefinitions) error {
+	templ := a.GenOpts.Sections.Mod
+	if a.GenOpts.Force {
+		templ.SkipExists = false
+	}
+	mod := GenKclMod{Name: a.GenOpts.ModName}
+	if mod.Name == "" {
+		target, err := filepath.Abs(a.Target)
+		if err != nil {
+			return err
+		}
+		mod.Name = filepath.Base(target)
+	}
+	if importsK8s(models) {
+		mod.Dependencies = append(mod.Dependencies, GenModDependency{Name: "k8s", Version: k8sModVersion})
+	}
+	return a.GenOpts.write(&templ, &mod)
+}
+
+// importsK8s tells if any of the models imports the k8s package, such as the CRDs importing its ObjectMeta
+func importsK8s(models GenDefinitions) bool {
+	for _, mod := range models {
+		for _, imp := range mod.Imports {
+			if imp.ImportPath == "k8s" || strings.HasPrefix(imp.ImportPath, "k8s.") {
+				return true
+			}
+		}
+	}
+	return false
+}
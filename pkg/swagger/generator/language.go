@@ -18,10 +18,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v2"
@@ -36,16 +40,31 @@ var (
 
 const (
 	RegexPkgPath = "regex"
+	UnitsPkgPath = "units"
 )
 
+// identifierRegexp matches the names usable as KCL identifiers, keyword escapes included
+var identifierRegexp = regexp.MustCompile(`^\$?[A-Za-z_][A-Za-z0-9_]*$`)
+
 func initLanguage() {
 	DefaultLanguageFunc = KclLangOpts
 }
 
-// LanguageOpts to describe a language to the code generator
+// LanguageOpts to describe a language to the code generator.
+//
+// The identifiers taken from the spec, i.e. the names of the models, of the properties and of the example
+// variables, are escaped with a "$" prefix when they are one of the ReservedWords, e.g. $schema, which is the
+// KCL escape of the keywords. The names the generator chooses itself, i.e. the files, the package directories
+// and the import aliases, are suffixed instead, e.g. schema_pkg, since "$" is not allowed in a path.
 type LanguageOpts struct {
-	ReservedWords    []string
-	SystemModules    []string
+	ReservedWords []string
+	SystemModules []string
+	// NoMangleFileSuffix leaves the file names ending with an OS, an arch or test as they are,
+	// instead of appending a _swagger suffix to them
+	NoMangleFileSuffix bool
+	// NoMangleDirName leaves the directories named vendor or internal as they are,
+	// instead of appending a _swagger suffix to them
+	NoMangleDirName  bool
 	BaseImportFunc   func(string) string            `json:"-"`
 	ImportsFunc      func(map[string]string) string `json:"-"`
 	reservedWordsSet map[string]struct{}
@@ -54,6 +73,8 @@ type LanguageOpts struct {
 	formatFunc       func(string, []byte) ([]byte, error)
 	fileNameFunc     func(string) string // language specific source file naming rules
 	dirNameFunc      func(string) string // language specific directory naming rules
+	// warnings records the warnings raised while mangling the names, see Generate
+	warnings *warningRecorder
 }
 
 // Init the language option
@@ -80,35 +101,56 @@ func (l *LanguageOpts) MangleName(name, suffix string) string {
 	return strings.Join([]string{name, suffix}, "_")
 }
 
-// MangleVarName makes sure a reserved word gets a safe name
+// MangleVarName makes sure a reserved word gets a safe name, e.g. $schema
 func (l *LanguageOpts) MangleVarName(name string) string {
-	nm := swag.ToVarName(name)
-	if _, ok := l.reservedWordsSet[nm]; !ok {
-		return nm
-	}
-	return nm + "Var"
+	return l.escapeReservedWord(swag.ToVarName(name))
 }
 
 // MangleModelName adds "$" prefix to name if it is conflict with KCL keyword
 func (l *LanguageOpts) MangleModelName(modelName string) string {
+	return l.mangleModelName(modelName, l.warnings)
+}
+
+// mangleModelName mangles the model name, the replaced symbols being reported to the warning recorder
+func (l *LanguageOpts) mangleModelName(modelName string, warnings *warningRecorder) string {
 	// replace all the "-" to "_" in the model name
 	lastDotIndex := strings.LastIndex(modelName, ".")
 	shortName := modelName[lastDotIndex+1:]
 	if strings.Contains(shortName, "-") {
-		log.Printf("[WARN] the modelName %s contains symbol '-' which is forbidden in KCL. Will be replaced by '_'", shortName)
+		warnings.warn(modelName, "the modelName %s contains symbol '-' which is forbidden in KCL. Will be replaced by '_'", shortName)
 		modelName = modelName[:lastDotIndex+1] + strings.Replace(shortName, "-", "_", -1)
 	}
+	return l.escapeReservedWord(modelName)
+}
+
+// escapeReservedWord prefixes a reserved word with "$", the KCL escape of the keywords, e.g. $schema
+func (l *LanguageOpts) escapeReservedWord(name string) string {
 	for _, kw := range l.ReservedWords {
-		if modelName == kw {
-			return fmt.Sprintf("$%s", modelName)
+		if name == kw {
+			return "$" + name
 		}
 	}
-	return modelName
+	return name
+}
+
+// ManglePropertyName mangles a property name like a model name and quotes it when it is
+// still not a valid KCL identifier, such as the numeric names "123" or "1.0".
+func (l *LanguageOpts) ManglePropertyName(name string) string {
+	return l.manglePropertyName(name, l.warnings)
+}
+
+// manglePropertyName mangles the property name, the replaced symbols being reported to the warning recorder
+func (l *LanguageOpts) manglePropertyName(name string, warnings *warningRecorder) string {
+	mangled := l.mangleModelName(name, warnings)
+	if identifierRegexp.MatchString(mangled) {
+		return mangled
+	}
+	return strconv.Quote(mangled)
 }
 
 // MangleFileName makes sure a file name gets a safe name
 func (l *LanguageOpts) MangleFileName(name string) string {
-	if l.fileNameFunc != nil {
+	if l.fileNameFunc != nil && !l.NoMangleFileSuffix {
 		return l.fileNameFunc(name)
 	}
 	return swag.ToFileName(name)
@@ -120,7 +162,7 @@ func (l *LanguageOpts) ManglePackageName(name, suffix string) string {
 	if name == "" {
 		return suffix
 	}
-	if l.dirNameFunc != nil {
+	if l.dirNameFunc != nil && !l.NoMangleDirName {
 		name = l.dirNameFunc(name)
 	}
 	pth := filepath.ToSlash(filepath.Clean(name)) // preserve path
@@ -140,6 +182,9 @@ func (l *LanguageOpts) ManglePackagePath(name string, suffix string) string {
 	return strings.Join(parts, "/")
 }
 
+// kclStringEscaper escapes the characters which can't appear as is in a double-quoted KCL string
+var kclStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
 func (l *LanguageOpts) ToKclValue(data interface{}) string {
 	if data == nil {
 		return "None"
@@ -174,7 +219,7 @@ func (l *LanguageOpts) ToKclValue(data interface{}) string {
 		content := strings.Join(sliceContents, ", ")
 		return fmt.Sprintf("[%s]", content)
 	case reflect.String:
-		return fmt.Sprintf("\"%s\"", data)
+		return fmt.Sprintf("\"%s\"", kclStringEscaper.Replace(value.String()))
 	case reflect.Int,
 		reflect.Int8,
 		reflect.Int16,
@@ -219,6 +264,37 @@ func (l *LanguageOpts) ToKclValue(data interface{}) string {
 	}
 }
 
+// ToKclPattern quotes a regular expression as a KCL raw string, so that its backslashes are kept as they are.
+// The double quotes and the line breaks, which would end the raw string, are escaped: the regular expression
+// matches them the same.
+func (l *LanguageOpts) ToKclPattern(pattern string) string {
+	var b strings.Builder
+	b.WriteString(`r"`)
+	backslashes := 0
+	for _, r := range pattern {
+		switch r {
+		case '"':
+			if backslashes%2 == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteRune(r)
+		}
+		if r == '\\' {
+			backslashes++
+		} else {
+			backslashes = 0
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
 // FormatContent formats a file with a language specific formatter
 func (l *LanguageOpts) FormatContent(name string, content []byte) ([]byte, error) {
 	if l.formatFunc != nil {
@@ -227,6 +303,33 @@ func (l *LanguageOpts) FormatContent(name string, content []byte) ([]byte, error
 	return content, nil
 }
 
+// kclFormatter is the kcl binary looked up in the PATH to format the generated code
+const kclFormatter = "kcl"
+
+// formatKclContent formats the KCL code of the file ffn with "kcl fmt". The content is returned as it is
+// when the kcl binary is not found in the PATH, so that the generation doesn't depend on it.
+func formatKclContent(ffn string, content []byte) ([]byte, error) {
+	kcl, err := exec.LookPath(kclFormatter)
+	if err != nil {
+		debugLog("%s is not formatted: %v", ffn, err)
+		return content, nil
+	}
+	// kcl fmt formats the files in place, the content is formatted in a temporary copy of the file
+	dir, err := os.MkdirTemp("", "kcl-fmt-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmpFile := filepath.Join(dir, filepath.Base(ffn))
+	if err := os.WriteFile(tmpFile, content, 0644); err != nil {
+		return nil, err
+	}
+	if output, err := exec.Command(kcl, "fmt", tmpFile).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("kcl fmt failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return os.ReadFile(tmpFile)
+}
+
 // NonEmptyValue checks if a value is non-empty
 func (l *LanguageOpts) NonEmptyValue(data interface{}) bool {
 	return data != nil
@@ -292,6 +395,10 @@ func KclLangOpts() *LanguageOpts {
 
 	opts := new(LanguageOpts)
 	opts.ReservedWords = []string{
+		"True",
+		"False",
+		"None",
+		"Undefined",
 		"import",
 		"as",
 		"rule",
@@ -313,6 +420,7 @@ func KclLangOpts() *LanguageOpts {
 		"final",
 		"lambda",
 		"all",
+		"any",
 		"filter",
 		"map",
 		"type",
@@ -332,10 +440,7 @@ func KclLangOpts() *LanguageOpts {
 		"file",
 	}
 
-	opts.formatFunc = func(ffn string, content []byte) ([]byte, error) {
-		// todo: support kcl code format
-		return content, nil
-	}
+	opts.formatFunc = formatKclContent
 
 	opts.fileNameFunc = func(name string) string {
 		// whenever a generated file name ends with a suffix
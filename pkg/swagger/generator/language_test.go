@@ -1,6 +1,12 @@
 package generator
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
 
 func TestEscapedModelName(t *testing.T) {
 	cases := []struct {
@@ -43,3 +49,219 @@ func TestEscapedModelName(t *testing.T) {
 		})
 	}
 }
+
+func TestManglePropertyName(t *testing.T) {
+	cases := []struct {
+		value  string
+		expect string
+	}{
+		{value: "name", expect: "name"},
+		{value: "max-size", expect: "max_size"},
+		{value: "123", expect: `"123"`},
+		{value: "1.0", expect: `"1.0"`},
+		{value: "v1.0", expect: `"v1.0"`},
+	}
+	opts := KclLangOpts()
+
+	for _, testcase := range cases {
+		t.Run(testcase.value, func(t *testing.T) {
+			got := opts.ManglePropertyName(testcase.value)
+			if got != testcase.expect {
+				t.Fatalf("unexpected output, expect:\n%s\ngot:%s\n", testcase.expect, got)
+			}
+		})
+	}
+}
+
+func TestMangleReservedWords(t *testing.T) {
+	opts := KclLangOpts()
+	for _, word := range opts.ReservedWords {
+		t.Run(word, func(t *testing.T) {
+			expect := "$" + word
+			if got := opts.MangleModelName(word); got != expect {
+				t.Fatalf("unexpected model name, expect:\n%s\ngot:%s\n", expect, got)
+			}
+			if got := opts.ManglePropertyName(word); got != expect {
+				t.Fatalf("unexpected property name, expect:\n%s\ngot:%s\n", expect, got)
+			}
+			// the var names are camelized, True becomes true which is not reserved
+			if strings.ToLower(word[:1]) != word[:1] {
+				expect = strings.ToLower(word[:1]) + word[1:]
+			}
+			if got := opts.MangleVarName(word); got != expect {
+				t.Fatalf("unexpected var name, expect:\n%s\ngot:%s\n", expect, got)
+			}
+		})
+	}
+	for _, name := range []string{"Schema", "schemas", "imports", "none"} {
+		t.Run(name, func(t *testing.T) {
+			if got := opts.MangleModelName(name); got != name {
+				t.Fatalf("unexpected model name, expect:\n%s\ngot:%s\n", name, got)
+			}
+			if got := opts.ManglePropertyName(name); got != name {
+				t.Fatalf("unexpected property name, expect:\n%s\ngot:%s\n", name, got)
+			}
+		})
+	}
+}
+
+func TestMangleFileSuffix(t *testing.T) {
+	cases := []struct {
+		value    string
+		noMangle bool
+		expect   string
+	}{
+		{value: "Deployment", expect: "deployment"},
+		{value: "DeploymentWindows", expect: "deployment_windows_swagger"},
+		{value: "NodeArm64", expect: "node_arm64_swagger"},
+		{value: "UnitTest", expect: "unit_test_swagger"},
+		{value: "Deployment", noMangle: true, expect: "deployment"},
+		{value: "DeploymentWindows", noMangle: true, expect: "deployment_windows"},
+		{value: "NodeArm64", noMangle: true, expect: "node_arm64"},
+		{value: "UnitTest", noMangle: true, expect: "unit_test"},
+	}
+
+	for _, testcase := range cases {
+		t.Run(testcase.value, func(t *testing.T) {
+			opts := KclLangOpts()
+			opts.NoMangleFileSuffix = testcase.noMangle
+			got := opts.MangleFileName(testcase.value)
+			if got != testcase.expect {
+				t.Fatalf("unexpected output, expect:\n%s\ngot:%s\n", testcase.expect, got)
+			}
+		})
+	}
+}
+
+func TestMangleDirName(t *testing.T) {
+	cases := []struct {
+		value    string
+		noMangle bool
+		expect   string
+	}{
+		{value: "vendor", expect: "vendor_swagger"},
+		{value: "internal", expect: "internal_swagger"},
+		{value: "models", expect: "models"},
+		{value: "vendor", noMangle: true, expect: "vendor"},
+		{value: "internal", noMangle: true, expect: "internal"},
+		{value: "models", noMangle: true, expect: "models"},
+	}
+
+	for _, testcase := range cases {
+		t.Run(testcase.value, func(t *testing.T) {
+			opts := KclLangOpts()
+			opts.NoMangleDirName = testcase.noMangle
+			got := opts.ManglePackageName(testcase.value, "")
+			if got != testcase.expect {
+				t.Fatalf("unexpected output, expect:\n%s\ngot:%s\n", testcase.expect, got)
+			}
+		})
+	}
+}
+
+func TestToKclPattern(t *testing.T) {
+	cases := []struct {
+		value  string
+		expect string
+	}{
+		{value: `^[a-z]+$`, expect: `r"^[a-z]+$"`},
+		{value: `^\d+\.\d+$`, expect: `r"^\d+\.\d+$"`},
+		{value: `^"[^"]*"$`, expect: `r"^\"[^\"]*\"$"`},
+		{value: `^\"$`, expect: `r"^\"$"`},
+		{value: `^\\"$`, expect: `r"^\\\"$"`},
+		{value: "^a\nb$", expect: `r"^a\nb$"`},
+	}
+	opts := KclLangOpts()
+
+	for _, testcase := range cases {
+		t.Run(testcase.value, func(t *testing.T) {
+			got := opts.ToKclPattern(testcase.value)
+			if got != testcase.expect {
+				t.Fatalf("unexpected output, expect:\n%s\ngot:%s\n", testcase.expect, got)
+			}
+		})
+	}
+}
+
+func TestFormatKclContent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake kcl binary is a shell script")
+	}
+	content := []byte("schema Pet:   \n    name: str\t\n")
+	path := os.Getenv("PATH")
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+
+	// without kcl, the content is left as it is
+	formatted, err := formatKclContent("pet.k", content)
+	if err != nil || string(formatted) != string(content) {
+		t.Fatalf("expect the content to be left unformatted, got %q: %v", formatted, err)
+	}
+
+	// a fake kcl fmt trimming the trailing whitespaces of the file in place, with the tools of the PATH
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+path)
+	script := "#!/bin/sh\n[ \"$1\" = fmt ] || exit 1\nsed 's/[[:space:]]*$//' \"$2\" > \"$2.tmp\" && mv \"$2.tmp\" \"$2\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "kcl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	formatted, err = formatKclContent(filepath.Join("models", "pet.k"), content)
+	if err != nil || string(formatted) != "schema Pet:\n    name: str\n" {
+		t.Fatalf("expect the content to be formatted by kcl fmt, got %q: %v", formatted, err)
+	}
+
+	script = "#!/bin/sh\necho \"error: invalid syntax\"\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(bin, "kcl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = formatKclContent("pet.k", content); err == nil || !strings.Contains(err.Error(), "kcl fmt failed: exit status 1: error: invalid syntax") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestFormatCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake kcl binary is a shell script")
+	}
+	bin := t.TempDir()
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	script := "#!/bin/sh\necho \"error: invalid syntax\"\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(bin, "kcl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	spec := `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Pet:
+    type: object
+    properties:
+      name:
+        type: string
+`
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "spec.yaml")
+	if err := os.WriteFile(specPath, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := new(GenOpts)
+	opts.Spec = specPath
+	opts.Target = filepath.Join(dir, "output")
+	opts.ModelPackage = "models"
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	// the generated files are formatted by default, a file kcl fmt fails to format fails the generation
+	// and is dumped unformatted for debugging purposes
+	err := Generate(opts)
+	if err == nil || !strings.Contains(err.Error(), "kcl fmt failed: exit status 1: error: invalid syntax") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(opts.Target, "models", "pet.k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(data), "schema Pet:\n", "    name?: str\n")
+}
@@ -0,0 +1,162 @@
+package generator
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/swag"
+)
+
+// mixinMinProperties is the minimum number of properties of a field group extracted into a mixin
+const mixinMinProperties = 2
+
+// mixinGroup is a group of identical properties shared by the same definitions
+type mixinGroup struct {
+	hosts      []string
+	properties []string
+}
+
+// extractMixins looks for the groups of identical properties shared by at least threshold definitions,
+// and moves each group into a new mixin definition, which the definitions mix in through the
+// x-kcl-mixins extension. Two properties are identical when they have the same name, the same schema
+// and are both required or both optional.
+func extractMixins(sw *spec.Swagger, threshold int) {
+	if threshold < 2 {
+		return
+	}
+	// the definitions sharing each property
+	hostsOf := make(map[string][]string)
+	fingerprints := make(map[string]map[string]string)
+	for _, name := range sortedDefinitionNames(sw) {
+		sch := sw.Definitions[name]
+		if !isMixinHost(&sch) {
+			continue
+		}
+		fingerprints[name] = make(map[string]string, len(sch.Properties))
+		for prop := range sch.Properties {
+			fingerprint, ok := propertyFingerprint(&sch, prop)
+			if !ok {
+				continue
+			}
+			fingerprints[name][prop] = fingerprint
+			hostsOf[fingerprint] = append(hostsOf[fingerprint], name)
+		}
+	}
+
+	// group the shared properties by the definitions sharing them
+	groups := make(map[string]*mixinGroup)
+	for name, props := range fingerprints {
+		for prop, fingerprint := range props {
+			hosts := hostsOf[fingerprint]
+			if len(hosts) < threshold || hosts[0] != name {
+				continue
+			}
+			key := strings.Join(hosts, ",")
+			if groups[key] == nil {
+				groups[key] = &mixinGroup{hosts: hosts}
+			}
+			groups[key].properties = append(groups[key].properties, prop)
+		}
+	}
+	keys := make([]string, 0, len(groups))
+	for key, group := range groups {
+		if len(group.properties) >= mixinMinProperties {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		extractMixin(sw, groups[key])
+	}
+}
+
+// extractMixin moves the properties of the group from its hosts into a new mixin definition.
+func extractMixin(sw *spec.Swagger, group *mixinGroup) {
+	sort.Strings(group.properties)
+	name := mixinName(sw, group.properties)
+	debugLog("extracting the properties %s shared by %s into the mixin %s", strings.Join(group.properties, ", "), strings.Join(group.hosts, ", "), name)
+
+	first := sw.Definitions[group.hosts[0]]
+	mixin := new(spec.Schema).Typed(object, "")
+	mixin.Description = "mixin of the properties shared by " + strings.Join(group.hosts, ", ")
+	for _, prop := range group.properties {
+		mixin.SetProperty(prop, first.Properties[prop])
+		if swag.ContainsStrings(first.Required, prop) {
+			mixin.Required = append(mixin.Required, prop)
+		}
+	}
+	sw.Definitions[name] = *mixin
+
+	for _, host := range group.hosts {
+		sch := sw.Definitions[host]
+		var required []string
+		for _, prop := range sch.Required {
+			if !swag.ContainsStrings(group.properties, prop) {
+				required = append(required, prop)
+			}
+		}
+		sch.Required = required
+		for _, prop := range group.properties {
+			delete(sch.Properties, prop)
+		}
+		mixins, _ := sch.Extensions[xKclMixins].([]interface{})
+		sch.AddExtension(xKclMixins, append(mixins, name))
+		sw.Definitions[host] = sch
+	}
+}
+
+// isMixinHost tells if a definition is a plain object, the properties of which may be moved into a mixin
+func isMixinHost(sch *spec.Schema) bool {
+	if len(sch.Properties) == 0 || len(sch.AllOf) > 0 || sch.Discriminator != "" {
+		return false
+	}
+	if _, ok := sch.Extensions[xKclType]; ok {
+		return false
+	}
+	return len(sch.Type) == 0 || sch.Type.Contains(object)
+}
+
+// propertyFingerprint identifies a property by its name, its schema and whether it is required.
+// The x-order extension, which depends on the position of the property, is left out.
+func propertyFingerprint(sch *spec.Schema, name string) (string, bool) {
+	prop := sch.Properties[name]
+	extensions := make(spec.Extensions, len(prop.Extensions))
+	for k, v := range prop.Extensions {
+		if k != xOrder {
+			extensions[k] = v
+		}
+	}
+	prop.Extensions = extensions
+	data, err := json.Marshal(prop)
+	if err != nil {
+		return "", false
+	}
+	return name + "/" + strconv.FormatBool(swag.ContainsStrings(sch.Required, name)) + "/" + string(data), true
+}
+
+// mixinName names a mixin after its properties, e.g. "LabelsOwnerMixin", avoiding the existing definitions
+func mixinName(sw *spec.Swagger, properties []string) string {
+	base := ""
+	for _, prop := range properties {
+		base += swag.ToGoName(prop)
+	}
+	name := base + "Mixin"
+	for i := 1; ; i++ {
+		if _, exists := sw.Definitions[name]; !exists {
+			return name
+		}
+		name = base + strconv.Itoa(i) + "Mixin"
+	}
+}
+
+func sortedDefinitionNames(sw *spec.Swagger) []string {
+	names := make([]string, 0, len(sw.Definitions))
+	for name := range sw.Definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
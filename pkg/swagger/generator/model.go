@@ -15,8 +15,11 @@
 package generator
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"log"
+	"math"
 	"path"
 	"path/filepath"
 	"reflect"
@@ -37,10 +40,17 @@ func makeGenDefinition(name, pkg string, schema spec.Schema, specDoc *loads.Docu
 }
 
 func makeGenDefinitionHierarchy(name, pkg, container string, schema spec.Schema, specDoc *loads.Document, opts *GenOpts) (*GenDefinition, error) {
-	receiver := "m"
 	// models are resolved in the current package
 	resolver := newTypeResolver("", specDoc)
 	resolver.ModelName = name
+	resolver.UnionMapping = newUnionMapping(opts.UnionMapping)
+	resolver.WarnUnknownFormats = opts.WarnUnknownFormats
+	resolver.warnings = opts.warnings
+	resolver.QuantityTypes = opts.QuantityTypes
+	resolver.QuantityFields = make(map[string]struct{}, len(opts.QuantityFields))
+	for _, field := range opts.QuantityFields {
+		resolver.QuantityFields[field] = struct{}{}
+	}
 	analyzed := analysis.New(specDoc.Spec())
 
 	di := discriminatorInfo(analyzed)
@@ -48,9 +58,6 @@ func makeGenDefinitionHierarchy(name, pkg, container string, schema spec.Schema,
 	pg := schemaGenContext{
 		Path:           "",
 		Name:           name,
-		Receiver:       receiver,
-		IndexVar:       "i",
-		ValueExpr:      receiver,
 		Schema:         schema,
 		Required:       false,
 		TypeResolver:   resolver,
@@ -59,6 +66,22 @@ func makeGenDefinitionHierarchy(name, pkg, container string, schema spec.Schema,
 		Discrimination: di,
 		Container:      container,
 		KeepOrder:      opts.KeepOrder,
+		SourceComments: opts.SourceComments,
+		SourcePath:     "#/definitions/" + escapeJSONPointer(name),
+
+		RequireOptionalChecks: opts.CheckOptional == CheckOptionalRequire,
+		ReadOnlyOptional:      opts.ReadOnlyOptional,
+		DropReadOnly:          opts.DropReadOnly,
+		ExcludeWriteOnly:      opts.ExcludeWriteOnly,
+		WithExamples:          opts.WithExamples,
+		FormatRangeChecks:     opts.FormatRangeChecks,
+		DateAsStrCheck:        opts.DateAsStrCheck,
+		FormatPatternChecks:   opts.FormatPatternChecks,
+		NonEmptyStrings:       opts.NonEmptyStrings,
+		StrictEnumTypes:       opts.StrictEnumTypes,
+		AnonNaming:            opts.AnonNaming,
+		AdditionalPropsName:   opts.AdditionalPropsName,
+		warnings:              opts.warnings,
 	}
 	if err := pg.makeGenSchema(); err != nil {
 		return nil, fmt.Errorf("could not generate schema for %s: %v", name, err)
@@ -86,6 +109,7 @@ func makeGenDefinitionHierarchy(name, pkg, container string, schema spec.Schema,
 		pg.GenSchema.DiscriminatorValue = dse.FieldValue
 		pg.GenSchema.IsSubType = true
 		knownProperties := make(map[string]struct{})
+		inherited := ""
 
 		// find the referenced definitions
 		// check if it has a discriminator defined
@@ -97,7 +121,7 @@ func makeGenDefinitionHierarchy(name, pkg, container string, schema spec.Schema,
 			for ref.String() != "" {
 				var rsch *spec.Schema
 				var err error
-				rsch, err = spec.ResolveRef(swsp, &ref)
+				rsch, _, err = resolveRef(swsp, ref)
 				if err != nil {
 					return nil, err
 				}
@@ -108,10 +132,20 @@ func makeGenDefinitionHierarchy(name, pkg, container string, schema spec.Schema,
 				}
 				ref = spec.Ref{}
 				if rsch != nil && rsch.Discriminator != "" {
-					gs, err := makeGenDefinitionHierarchy(strings.TrimPrefix(ss.Ref.String(), "#/definitions/"), pkg, pg.GenSchema.Name, *rsch, specDoc, opts)
+					baseRef := normalizeRef(ss.Ref)
+					gs, err := makeGenDefinitionHierarchy(strings.TrimPrefix(baseRef.String(), "#/definitions/"), pkg, pg.GenSchema.Name, *rsch, specDoc, opts)
 					if err != nil {
 						return nil, err
 					}
+					if inherited != "" {
+						// a kcl schema inherits a single schema: the properties of the other base types are copied
+						opts.warnings.warn(name, "%s is a subtype of several base types, only %s is inherited, the properties of %s are copied",
+							name, inherited, gs.GenSchema.Name)
+						gs.GenSchema.IsBaseType = false
+						pg.GenSchema.AllOf[i] = gs.GenSchema
+						continue
+					}
+					inherited = gs.GenSchema.Name
 					gs.GenSchema.IsBaseType = true
 					gs.GenSchema.IsExported = true
 					pg.GenSchema.AllOf[i] = gs.GenSchema
@@ -144,24 +178,68 @@ func makeGenDefinitionHierarchy(name, pkg, container string, schema spec.Schema,
 			}
 			pg.GenSchema.AllOf[i].Properties = remainingProperties
 		}
+		pinDiscriminatorValue(&pg.GenSchema)
 	}
 
+	var example string
+	if opts.GenExamples {
+		example = exampleInstance(&pg.GenSchema)
+	}
 	return &GenDefinition{
 		GenCommon: GenCommon{
 			Copyright:        opts.Copyright,
 			TargetImportPath: opts.LanguageOpts.baseImport(opts.Target),
 		},
-		Package:      opts.LanguageOpts.ManglePackageName(path.Base(filepath.ToSlash(pkg)), "definitions"),
-		GenSchema:    pg.GenSchema,
-		DependsOn:    pg.Dependencies,
-		ExtraSchemas: gatherExtraSchemas(pg.ExtraSchemas),
-		Imports:      pg.collectSortedImports(),
+		Package:         opts.LanguageOpts.ManglePackageName(path.Base(filepath.ToSlash(pkg)), "definitions"),
+		GenSchema:       pg.GenSchema,
+		DependsOn:       pg.Dependencies,
+		ExtraSchemas:    gatherExtraSchemas(pg.ExtraSchemas),
+		Imports:         pg.collectSortedImports(),
+		ExampleInstance: example,
 		// To avoid conflicts between the attributes of the schema and the names of
 		// the regex module, we represent the `regex.match` function with `regex_match = regex.match`
 		HasPatternValidation: pg.HasPatternValidation,
 	}, nil
 }
 
+// pinDiscriminatorValue defaults the discriminator property of a subtype to the value telling the subtype apart,
+// e.g. petType: str = "Cat". The discriminator property of the base type is redefined by the subtype.
+func pinDiscriminatorValue(sch *GenSchema) {
+	field, value := sch.DiscriminatorField, sch.DiscriminatorValue
+	pin := func(props GenSchemaList) bool {
+		for i := range props {
+			if props[i].Name == field {
+				if props[i].Default == nil {
+					props[i].Default = value
+				}
+				return true
+			}
+		}
+		return false
+	}
+	if pin(sch.Properties) {
+		return
+	}
+	for i := range sch.AllOf {
+		if !sch.AllOf[i].IsBaseType && pin(sch.AllOf[i].Properties) {
+			return
+		}
+	}
+	for _, base := range sch.AllOf {
+		if !base.IsBaseType {
+			continue
+		}
+		for _, prop := range base.Properties {
+			if prop.Name == field {
+				prop.IsBaseType = false
+				prop.Default = value
+				sch.Properties = append(sch.Properties, prop)
+				return
+			}
+		}
+	}
+}
+
 type importStmt struct {
 	ImportPath string
 	AsName     string
@@ -215,12 +293,18 @@ func (schema *GenSchema) getBuiltInImports() map[string]importStmt {
 			imp[k] = v
 		}
 	}
-	if schema.Pattern != "" {
+	if schema.Pattern != "" || schema.KeyPattern != "" {
 		imp[RegexPkgPath] = importStmt{
 			ImportPath: RegexPkgPath,
 			IsBuiltIn:  true,
 		}
 	}
+	if schema.IsQuantity {
+		imp[UnitsPkgPath] = importStmt{
+			ImportPath: UnitsPkgPath,
+			IsBuiltIn:  true,
+		}
+	}
 	if schema.Items != nil {
 		for k, v := range schema.Items.getBuiltInImports() {
 			imp[k] = v
@@ -250,26 +334,33 @@ func getImportAsName(imp map[string]importStmt, pkg string, module string) strin
 	parts := strings.Split(pkg, ".")
 	asName := ""
 	for i := len(parts) - 1; i >= 0; i-- {
-		conflict := false
 		// when conflict with other import as name, the `import as` name will be "{parentPkgName}strings.Title({PkgAlias})"
 		asName = parts[i] + strings.ToTitle(asName)
-		for _, v := range imp {
-			if v.AsName == asName {
-				conflict = true
-				break
-			}
+		// the alias is used as an identifier, which must not be a reserved word such as "schema"
+		mangled := DefaultLanguageFunc().MangleName(asName, "pkg")
+		if !importAsNameTaken(imp, mangled) {
+			return mangled
 		}
-		if !conflict {
-			return asName
+	}
+	// every name made of the parts of the path is taken, e.g. by a subpackage of the models at the same path,
+	// the name made of the whole path is numbered
+	for i := 2; ; i++ {
+		numbered := asName + "_" + strconv.Itoa(i)
+		if !importAsNameTaken(imp, numbered) {
+			debugLog("the import of %s in module %s is named %s", pkg, module, numbered)
+			return numbered
 		}
 	}
-	mangledAsName := "kclMangled" + strings.ToTitle(asName)
+}
+
+// importAsNameTaken tells if one of the imports is already named name
+func importAsNameTaken(imp map[string]importStmt, name string) bool {
 	for _, v := range imp {
-		if v.AsName == asName {
-			log.Printf("[WARN] the import paths in module %s.%s are confict, please resolve it properly", pkg, module)
+		if v.AsName == name {
+			return true
 		}
 	}
-	return mangledAsName
+	return false
 }
 
 // collectImports collect import paths from the sch to the toPkg, the result will be collected to the importStmt map.
@@ -291,13 +382,37 @@ func collectImports(sch *GenSchema, toPkg string, imp map[string]importStmt) {
 	}
 	if sch.AdditionalProperties != nil {
 		collectImports(sch.AdditionalProperties, toPkg, imp)
-		sch.KclType = "{str:" + sch.AdditionalProperties.KclType + "}"
+		if len(sch.Properties) == 0 {
+			// an object with properties is a schema, its additional properties are typed by an index signature
+			sch.KclType = "{str:" + sch.AdditionalProperties.KclType + "}"
+		}
 	}
 	if sch.AllOf != nil {
 		for idx := range sch.AllOf {
+			if sch.AllOf[idx].IsBaseType {
+				// a discriminated base type is only rendered as a parent of the schema,
+				// its properties are imported by the module of the base type itself
+				collectTypeImport(&sch.AllOf[idx], toPkg, imp)
+				continue
+			}
 			collectImports(&sch.AllOf[idx], toPkg, imp)
 		}
 	}
+	if union := sch.Union(); union != nil {
+		isUnion := sch.KclType == unionType(union)
+		for idx := range union {
+			collectImports(&union[idx], toPkg, imp)
+		}
+		if isUnion {
+			sch.KclType = unionType(union)
+		}
+	}
+	collectTypeImport(sch, toPkg, imp)
+}
+
+// collectTypeImport collects the import path of the package of the sch type alone to the toPkg,
+// and prefixes the KclType with the name it is imported as.
+func collectTypeImport(sch *GenSchema, toPkg string, imp map[string]importStmt) {
 	if sch.Pkg == toPkg || sch.Pkg == "" {
 		// the model to import and to import to belong to the same package,
 		// or the model to import has empty pkg(that means the model is a basic type)
@@ -332,14 +447,30 @@ func collectImports(sch *GenSchema, toPkg string, imp map[string]importStmt) {
 }
 
 type schemaGenContext struct {
-	Required                   bool
-	AdditionalProperty         bool
-	Named                      bool
-	RefHandled                 bool
-	IsVirtual                  bool
-	IsTuple                    bool
+	Required           bool
+	AdditionalProperty bool
+	Named              bool
+	RefHandled         bool
+	IsVirtual          bool
+	IsTuple            bool
+	// IsElement is set on the items of an array and on the values of a map, whose enum is rendered
+	// as a literal union within the type of the container, e.g. ["a" | "b"]
+	IsElement                  bool
 	StrictAdditionalProperties bool
 	KeepOrder                  bool
+	SourceComments             bool
+	RequireOptionalChecks      bool
+	ReadOnlyOptional           bool
+	DropReadOnly               bool
+	ExcludeWriteOnly           bool
+	WithExamples               bool
+	FormatRangeChecks          bool
+	DateAsStrCheck             bool
+	FormatPatternChecks        bool
+	NonEmptyStrings            bool
+	StrictEnumTypes            bool
+	AnonNaming                 string
+	AdditionalPropsName        string
 	HasPatternValidation       bool
 	Index                      int
 
@@ -347,11 +478,8 @@ type schemaGenContext struct {
 	Name         string
 	ParamName    string
 	Accessor     string
-	Receiver     string
-	IndexVar     string
-	KeyVar       string
-	ValueExpr    string
 	Container    string
+	SourcePath   string // JSON pointer to the schema in the original spec
 	Schema       spec.Schema
 	TypeResolver *typeResolver
 
@@ -361,31 +489,22 @@ type schemaGenContext struct {
 	Discriminator  *discor
 	Discriminated  *discee
 	Discrimination *discInfo
+
+	// warnings records the warnings raised during the generation of the schema
+	warnings *warningRecorder
 }
 
 func (sg *schemaGenContext) NewArrayBranch(schema *spec.Schema) *schemaGenContext {
 	debugLog("new array branch %s (model: %s)", sg.Name, sg.TypeResolver.ModelName)
 	pg := sg.shallowClone()
-	indexVar := pg.IndexVar
-	if pg.Path == "" {
-		pg.Path = indexVar
-	} else {
-		pg.Path = pg.Path + "." + indexVar
-	}
-	// check who is parent, if it's a base type then rewrite the value expression
-	if sg.Discrimination != nil && sg.Discrimination.Discriminators != nil {
-		_, rewriteValueExpr := sg.Discrimination.Discriminators["#/definitions/"+sg.TypeResolver.ModelName]
-		if (pg.IndexVar == "i" && rewriteValueExpr) || (sg.GenSchema.ElemType != nil && sg.GenSchema.ElemType.IsBaseType) {
-			pg.ValueExpr = sg.Receiver
-		}
-	}
+	pg.Path = childPath(sg.Path, "items")
 	if sg.GenSchema.ElemType != nil {
 		sg.GenSchema.IsBaseType = sg.GenSchema.ElemType.HasDiscriminator
 	}
-	pg.IndexVar = indexVar + "i"
-	pg.ValueExpr = pg.ValueExpr + "[" + indexVar + "]"
+	pg.SourcePath = sg.SourcePath + "/items"
 	pg.Schema = *schema
 	pg.Required = false
+	pg.IsElement = true
 	if sg.IsVirtual {
 		pg.TypeResolver = sg.TypeResolver.NewWithModelName(sg.TypeResolver.ModelName)
 	}
@@ -398,23 +517,9 @@ func (sg *schemaGenContext) NewAdditionalItems(schema *spec.Schema) *schemaGenCo
 	debugLog("new additional items\n")
 
 	pg := sg.shallowClone()
-	indexVar := pg.IndexVar
 	pg.Name = sg.Name + " items"
-	itemsLen := 0
-	if sg.Schema.Items != nil {
-		itemsLen = sg.Schema.Items.Len()
-	}
-	var mod string
-	if itemsLen > 0 {
-		mod = "+" + strconv.Itoa(itemsLen)
-	}
-	if pg.Path == "" {
-		pg.Path = "(" + indexVar + mod + ")"
-	} else {
-		pg.Path = pg.Path + ".(" + indexVar + mod + ")"
-	}
-	pg.IndexVar = indexVar
-	pg.ValueExpr = sg.ValueExpr + "." + pascalize(sg.KclName()) + "Items[" + indexVar + "]"
+	pg.Path = childPath(sg.Path, "additionalItems")
+	pg.SourcePath = sg.SourcePath + "/additionalItems"
 	pg.Schema = spec.Schema{}
 	if schema != nil {
 		pg.Schema = *schema
@@ -427,12 +532,8 @@ func (sg *schemaGenContext) NewTupleElement(schema *spec.Schema, index int) *sch
 	debugLog("New tuple element\n")
 
 	pg := sg.shallowClone()
-	if pg.Path == "" {
-		pg.Path = strconv.Itoa(index)
-	} else {
-		pg.Path = pg.Path + "." + strconv.Itoa(index)
-	}
-	pg.ValueExpr = pg.ValueExpr + ".P" + strconv.Itoa(index)
+	pg.Path = childPath(sg.Path, strconv.Itoa(index))
+	pg.SourcePath = sg.SourcePath + "/items/" + strconv.Itoa(index)
 
 	pg.Required = true
 	pg.IsTuple = true
@@ -444,13 +545,9 @@ func (sg *schemaGenContext) NewTupleElement(schema *spec.Schema, index int) *sch
 func (sg *schemaGenContext) NewSchemaBranch(name string, schema spec.Schema) *schemaGenContext {
 	debugLog("new schema branch %s (parent %s)", sg.Name, sg.Container)
 	pg := sg.shallowClone()
-	if sg.Path == "" {
-		pg.Path = name
-	} else {
-		pg.Path = fmt.Sprintf("%s.%s", pg.Path, name)
-	}
+	pg.Path = childPath(sg.Path, name)
 	pg.Name = name
-	pg.ValueExpr = pg.ValueExpr + "." + pascalize(kclName(&schema, name))
+	pg.SourcePath = sg.SourcePath + "/properties/" + escapeJSONPointer(name)
 	pg.Schema = schema
 	for _, fn := range sg.Schema.Required {
 		if name == fn {
@@ -459,8 +556,8 @@ func (sg *schemaGenContext) NewSchemaBranch(name string, schema spec.Schema) *sc
 		}
 	}
 
-	if pg.Schema.Default != nil && pg.Schema.ReadOnly {
-		pg.Required = true
+	if pg.Schema.ReadOnly && sg.ReadOnlyOptional {
+		pg.Required = false
 	}
 	debugLog("made new schema branch %s (parent %s)", pg.Name, pg.Container)
 	return pg
@@ -478,8 +575,22 @@ func (sg *schemaGenContext) shallowClone() *schemaGenContext {
 	pg.Named = false
 	pg.Index = 0
 	pg.IsTuple = false
+	pg.IsElement = false
 	pg.StrictAdditionalProperties = sg.StrictAdditionalProperties
 	pg.KeepOrder = sg.KeepOrder
+	pg.SourceComments = sg.SourceComments
+	pg.RequireOptionalChecks = sg.RequireOptionalChecks
+	pg.ReadOnlyOptional = sg.ReadOnlyOptional
+	pg.DropReadOnly = sg.DropReadOnly
+	pg.ExcludeWriteOnly = sg.ExcludeWriteOnly
+	pg.WithExamples = sg.WithExamples
+	pg.FormatRangeChecks = sg.FormatRangeChecks
+	pg.DateAsStrCheck = sg.DateAsStrCheck
+	pg.FormatPatternChecks = sg.FormatPatternChecks
+	pg.NonEmptyStrings = sg.NonEmptyStrings
+	pg.StrictEnumTypes = sg.StrictEnumTypes
+	pg.AnonNaming = sg.AnonNaming
+	pg.AdditionalPropsName = sg.AdditionalPropsName
 	return pg
 }
 
@@ -492,6 +603,7 @@ func (sg *schemaGenContext) NewCompositionBranch(schema spec.Schema, index int)
 		pg.Name = sg.Name + pg.Name
 	}
 	pg.Index = index
+	pg.SourcePath = sg.SourcePath + "/allOf/" + strconv.Itoa(index)
 	debugLog("made new composition branch %s (parent: %s)", pg.Name, pg.Container)
 	return pg
 }
@@ -509,31 +621,35 @@ func (sg *schemaGenContext) NewBranch(schema spec.Schema) *schemaGenContext {
 }
 
 func (sg *schemaGenContext) NewAdditionalProperty(schema spec.Schema) *schemaGenContext {
-	debugLog("new additional property %s (expr: %s)", sg.Name, sg.ValueExpr)
+	debugLog("new additional property %s (path: %s)", sg.Name, sg.Path)
 	pg := sg.shallowClone()
 	pg.Schema = schema
-	if pg.KeyVar == "" {
-		pg.ValueExpr = sg.ValueExpr
-	}
-	pg.KeyVar += "k"
-	pg.ValueExpr += "[" + pg.KeyVar + "]"
-	pg.Path = pg.KeyVar
+	pg.Path = childPath(sg.Path, "additionalProperties")
+	pg.SourcePath = sg.SourcePath + "/additionalProperties"
 	pg.GenSchema.Suffix = "Value"
-	if sg.Path != "" {
-		pg.Path = sg.Path + "." + pg.KeyVar
-	}
+	pg.IsElement = true
 	return pg
 }
 
+// childPath returns the path of a nested schema, which locates the schema within its model in the log messages
+func childPath(path, child string) string {
+	if path == "" {
+		return child
+	}
+	return path + "." + child
+}
+
 func hasSliceValidations(model *spec.Schema) (hasSliceValidations bool) {
-	hasSliceValidations = model.MaxItems != nil || model.MinItems != nil || model.UniqueItems
+	hasSliceValidations = model.MaxItems != nil || nonZeroBound(model.MinItems) != nil || model.UniqueItems
 	return
 }
 
 func hasValidations(model *spec.Schema) (hasValidation bool) {
 	hasNumberValidation := model.Maximum != nil || model.Minimum != nil || model.MultipleOf != nil
-	hasStringValidation := model.MaxLength != nil || model.MinLength != nil || model.Pattern != ""
-	hasValidation = hasNumberValidation || hasStringValidation || hasSliceValidations(model)
+	hasStringValidation := model.MaxLength != nil || nonZeroBound(model.MinLength) != nil || model.Pattern != ""
+	minProperties, maxProperties := propertiesBounds(model)
+	hasMapValidation := minProperties != nil || maxProperties != nil || hasKeyValidations(model)
+	hasValidation = hasNumberValidation || hasStringValidation || hasSliceValidations(model) || hasMapValidation
 	return
 }
 
@@ -548,31 +664,115 @@ func handleFormatConflicts(model *spec.Schema) {
 	}
 }
 
-func (sg *schemaGenContext) schemaValidations() sharedValidations {
+// applyFormatRange tightens the bounds of a sized integer to the range of its format, e.g. [-128, 127] for int8
+func applyFormatRange(model *spec.Schema) {
+	r, ok := integerFormatRanges[model.Format]
+	if !ok || !(model.Type.Contains(integer) || model.Type.Contains(number)) {
+		return
+	}
+	if model.Minimum == nil || *model.Minimum < r.min {
+		min := r.min
+		model.Minimum = &min
+		model.ExclusiveMinimum = false
+	}
+	if r.hasMax && (model.Maximum == nil || *model.Maximum > r.max) {
+		max := r.max
+		model.Maximum = &max
+		model.ExclusiveMaximum = false
+	}
+}
+
+// applyFormatPattern checks a string against the pattern of its format among the patterns, e.g. the date pattern
+// of a date, which replaces the pattern of the string, if any
+func applyFormatPattern(model *spec.Schema, patterns map[string]string) {
+	pattern, ok := patterns[strings.Replace(model.Format, "-", "", -1)]
+	if !ok || !model.Type.Contains(str) {
+		return
+	}
+	model.Pattern = pattern
+}
+
+// handleMultipleOfConflicts drops a fractional multipleOf of an integer, which no integer but 0 is a multiple of
+func (sg *schemaGenContext) handleMultipleOfConflicts(model *spec.Schema) {
+	if model.MultipleOf == nil || !model.Type.Contains(integer) || *model.MultipleOf == math.Trunc(*model.MultipleOf) {
+		return
+	}
+	sg.warnings.warn(sg.TypeResolver.ModelName, "the multipleOf %v of the integer %s is not an integer, the multipleOf check is skipped", *model.MultipleOf, sg.Name)
+	model.MultipleOf = nil
+}
+
+// applyFormatChecks adds the checks implied by the format of a value, as configured by the options
+func (sg *schemaGenContext) applyFormatChecks(model *spec.Schema) {
+	if sg.FormatRangeChecks {
+		applyFormatRange(model)
+	}
+	if sg.DateAsStrCheck {
+		applyFormatPattern(model, dateFormatPatterns)
+	}
+	if sg.FormatPatternChecks {
+		applyFormatPattern(model, formatPatterns)
+	}
+	if sg.TypeResolver.QuantityTypes && model.Format == quantity && model.Pattern == "" {
+		model.Pattern = quantityPattern
+	}
+}
+
+// markQuantity sets the quantity format on the string and x-kubernetes-int-or-string values without a format
+// of the properties named as quantity fields, as well as on the values of their arrays and maps, such as
+// the values of the limits of the resources of a container
+func (sg *schemaGenContext) markQuantity() {
+	if !sg.TypeResolver.QuantityTypes || sg.Schema.Format != "" || sg.Schema.Ref.String() != "" {
+		return
+	}
+	if _, ok := sg.TypeResolver.QuantityFields[sg.Name]; !ok {
+		return
+	}
+	isIntOrStr, ok := sg.Schema.Extensions.GetBool(k8sIntOrStrFlag)
+	if (isIntOrStr && ok) || (len(sg.Schema.Type) == 1 && sg.Schema.Type[0] == str) {
+		sg.Schema.Format = quantity
+	}
+}
+
+func (sg *schemaGenContext) schemaValidations() (sharedValidations, error) {
 	model := sg.Schema
 	// resolve any conflicting properties if the model has a format
 	handleFormatConflicts(&model)
-	s := sharedValidationsFromSchema(model, *sg)
+	sg.handleMultipleOfConflicts(&model)
+	sg.applyFormatChecks(&model)
+	if sg.NonEmptyStrings && sg.Required && model.Type.Contains(str) && model.MinLength == nil {
+		// a required string without an explicit minLength is non-empty
+		minLength := int64(1)
+		model.MinLength = &minLength
+	}
+	s, err := sharedValidationsFromSchema(model, *sg)
+	if err != nil {
+		return s, err
+	}
 
 	s.HasValidations = hasValidations(&model)
 	s.HasSliceValidations = hasSliceValidations(&model)
-	return s
+	return s, nil
 }
 
 func mergeValidation(other *schemaGenContext) bool {
+	return liftsValidation(&other.GenSchema)
+}
+
+// liftsValidation tells if the schema has validations which make its container validate it
+func liftsValidation(sch *GenSchema) bool {
 	// NOTE: NeedsRequired and NeedsValidation are deprecated
-	if other.GenSchema.AdditionalProperties != nil && other.GenSchema.AdditionalProperties.HasValidations {
+	if sch.AdditionalProperties != nil && sch.AdditionalProperties.HasValidations {
 		return true
 	}
-	if other.GenSchema.AdditionalItems != nil && other.GenSchema.AdditionalItems.HasValidations {
+	if sch.AdditionalItems != nil && sch.AdditionalItems.HasValidations {
 		return true
 	}
-	for _, sch := range other.GenSchema.AllOf {
-		if sch.HasValidations {
+	for _, one := range sch.AllOf {
+		if one.HasValidations {
 			return true
 		}
 	}
-	return other.GenSchema.HasValidations
+	return sch.HasValidations
 }
 
 func (sg *schemaGenContext) MergeResult(other *schemaGenContext, liftsRequired bool) {
@@ -599,7 +799,30 @@ func (sg *schemaGenContext) MergeResult(other *schemaGenContext, liftsRequired b
 func (sg *schemaGenContext) buildProperties() error {
 	debugLog("building properties %s (parent: %s)", sg.Name, sg.Container)
 
-	for k, v := range sg.Schema.Properties {
+	// the properties are built in the order of their names, so that the new schemas made for their anonymous
+	// objects are named the same way from a generation to another
+	names := make([]string, 0, len(sg.Schema.Properties))
+	for k := range sg.Schema.Properties {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	// the validation status before the properties are merged, which is restored when their checks are skipped
+	validated := sg.GenSchema.HasValidations
+	for _, k := range names {
+		v := sg.Schema.Properties[k]
+		if sg.Schema.ReadOnly {
+			// the fields of a read-only object, such as an array element, are read-only as well
+			v.ReadOnly = true
+		}
+		if v.ReadOnly && sg.DropReadOnly {
+			debugLog("dropping the read-only property %s[%q]", sg.Name, k)
+			continue
+		}
+		if sg.ExcludeWriteOnly && isWriteOnly(&v) {
+			// a required write-only property is left out as well, the value isn't part of the model
+			debugLog("dropping the write-only property %s[%q]", sg.Name, k)
+			continue
+		}
 		debugLogAsJSON("building property %s[%q] (tup: %t) (BaseType: %t)",
 			sg.Name, k, sg.IsTuple, sg.GenSchema.IsBaseType, sg.Schema)
 		debugLog("property %s[%q] (tup: %t) HasValidations: %t)",
@@ -615,8 +838,9 @@ func (sg *schemaGenContext) buildProperties() error {
 
 		if tpe.IsComplexObject && tpe.IsAnonymous && len(v.Properties) > 0 {
 			// this is an anonymous complex construct: build a new type for it
-			pg := sg.makeNewSchema(sg.Name+swag.ToGoName(k), v)
+			pg := sg.makeAnonSchema(k, sg.Name+swag.ToGoName(k), v)
 			pg.IsTuple = sg.IsTuple
+			pg.SourcePath = sg.SourcePath + "/properties/" + escapeJSONPointer(k)
 			if sg.Path == "" {
 				pg.Path = k
 			} else {
@@ -632,6 +856,10 @@ func (sg *schemaGenContext) buildProperties() error {
 			}
 
 			vv = *spec.RefProperty("#/definitions/" + pg.Name)
+			// the new type takes the place of the property, which keeps its position
+			if order, ok := v.Extensions[xOrder]; ok {
+				vv.AddExtension(xOrder, order)
+			}
 			sg.ExtraSchemas[pg.Name] = pg.GenSchema
 			// NOTE: MergeResult lifts validation status and extra schemas
 			sg.MergeResult(pg, false)
@@ -651,7 +879,7 @@ func (sg *schemaGenContext) buildProperties() error {
 				var rsch *spec.Schema
 				var err error
 				specDoc := sg.TypeResolver.Doc
-				rsch, err = spec.ResolveRef(specDoc.Spec(), &ref)
+				rsch, _, err = resolveRef(specDoc.Spec(), ref)
 				if err != nil {
 					return err
 				}
@@ -675,7 +903,8 @@ func (sg *schemaGenContext) buildProperties() error {
 			}
 
 			// set property name
-			var nm = filepath.Base(emprop.Schema.Ref.GetURL().Fragment)
+			propRef := normalizeRef(emprop.Schema.Ref)
+			var nm = filepath.Base(propRef.GetURL().Fragment)
 			tr := sg.TypeResolver.NewWithModelName(kclName(&emprop.Schema, swag.ToGoName(nm)))
 			_, err := tr.ResolveSchema(sch, false, true)
 			if err != nil {
@@ -695,9 +924,80 @@ func (sg *schemaGenContext) buildProperties() error {
 		sg.GenSchema.Properties = append(sg.GenSchema.Properties, emprop.GenSchema)
 	}
 	sort.Sort(sg.GenSchema.Properties)
+	sg.disambiguatePropertyNames()
+	sg.skipQuotedPropertyChecks(validated)
 	return nil
 }
 
+// skipQuotedPropertyChecks leaves out the checks of the properties with a quoted name, such as "1.0": the name
+// is a string literal in a check block, not a reference to the attribute, so the checks would test the name
+// instead of the value. The validation status of the schema is built again without these properties.
+func (sg *schemaGenContext) skipQuotedPropertyChecks(validated bool) {
+	skipped := false
+	for i := range sg.GenSchema.Properties {
+		prop := &sg.GenSchema.Properties[i]
+		if !strings.HasPrefix(prop.EscapedName, `"`) || !liftsValidation(prop) {
+			continue
+		}
+		sg.warnings.warn(sg.Name, "the checks of the property %s are not generated, since its quoted name can't be referenced in a check block", prop.EscapedName)
+		prop.SkipChecks = true
+		skipped = true
+	}
+	if !skipped {
+		return
+	}
+	for i := range sg.GenSchema.Properties {
+		if prop := &sg.GenSchema.Properties[i]; !prop.SkipChecks && liftsValidation(prop) {
+			validated = true
+		}
+	}
+	sg.GenSchema.HasValidations = validated
+}
+
+// disambiguatePropertyNames renames the properties mangled to the same attribute name, such as "type" and
+// "$type", which both become $type. The property left as it is by the mangling keeps the attribute name,
+// or else the first one by name, while the others are suffixed with the first number which is not taken.
+func (sg *schemaGenContext) disambiguatePropertyNames() {
+	props := sg.GenSchema.Properties
+	used := make(map[string]struct{}, len(props))
+	byName := make(map[string][]int, len(props))
+	for i, p := range props {
+		used[p.EscapedName] = struct{}{}
+		byName[p.EscapedName] = append(byName[p.EscapedName], i)
+	}
+	names := make([]string, 0, len(byName))
+	for name, idx := range byName {
+		if len(idx) > 1 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		idx := byName[name]
+		sort.Slice(idx, func(i, j int) bool {
+			if pi, pj := props[idx[i]].Name == name, props[idx[j]].Name == name; pi != pj {
+				return pi
+			}
+			return props[idx[i]].Name < props[idx[j]].Name
+		})
+		base := strings.TrimPrefix(name, "$")
+		for _, i := range idx[1:] {
+			renamed := base
+			for n := 1; ; n++ {
+				renamed = base + "_" + strconv.Itoa(n)
+				if _, ok := used[renamed]; !ok {
+					break
+				}
+			}
+			used[renamed] = struct{}{}
+			sg.warnings.warn(sg.TypeResolver.ModelName, "the property %s of %s is mangled to %s like the property %s, it is renamed to %s",
+				props[i].Name, sg.Name, name, props[idx[0]].Name, renamed)
+			props[i].EscapedName = renamed
+			props[i].Renamed = true
+		}
+	}
+}
+
 func (sg *schemaGenContext) buildAllOf() error {
 	if len(sg.Schema.AllOf) == 0 {
 		return nil
@@ -709,11 +1009,29 @@ func (sg *schemaGenContext) buildAllOf() error {
 		sg.Container = sg.Name
 	}
 	debugLogAsJSON("building all of for %d entries", len(sg.Schema.AllOf), sg.Schema)
+	// the first referenced object is inherited, unless a discriminated base type is
+	inherited, hasBaseType := -1, false
 	for i, sch := range sg.Schema.AllOf {
 		tpe, ert := sg.TypeResolver.ResolveSchema(&sch, sch.Ref.String() == "", false)
 		if ert != nil {
 			return ert
 		}
+		if inherited != -1 && sch.Ref.String() != "" && tpe.IsComplexObject && !tpe.IsBaseType && !tpe.IsArray && !tpe.IsMap {
+			// a kcl schema inherits a single schema: the properties of the other referenced objects are copied
+			sg.warnings.warn(sg.TypeResolver.ModelName, "the allOf branches of %s refer to several schemas, only %s is inherited, the properties of %s are copied",
+				sg.Container, sg.GenSchema.AllOf[inherited].KclType, tpe.KclType)
+			ref := sch.Ref
+			for ref.String() != "" {
+				rsch, _, err := resolveRef(sg.TypeResolver.Doc.Spec(), ref)
+				if err != nil {
+					return err
+				}
+				sch, ref = *rsch, rsch.Ref
+			}
+			if tpe, ert = sg.TypeResolver.ResolveSchema(&sch, true, false); ert != nil {
+				return ert
+			}
+		}
 
 		// check for multiple arrays in allOf branches.
 		// Although a valid JSON-Schema construct, it is not suited for serialization.
@@ -765,20 +1083,262 @@ func (sg *schemaGenContext) buildAllOf() error {
 		if err := comprop.makeGenSchema(); err != nil {
 			return err
 		}
-		if comprop.GenSchema.IsMap && comprop.GenSchema.HasAdditionalProperties && comprop.GenSchema.AdditionalProperties != nil {
-			// the anonymous branch is a map for AdditionalProperties: rewrite value expression
-			comprop.GenSchema.ValueExpression = comprop.GenSchema.ValueExpression + "." + comprop.Name
-			comprop.GenSchema.AdditionalProperties.ValueExpression = comprop.GenSchema.ValueExpression + "[" + comprop.GenSchema.AdditionalProperties.KeyVar + "]"
-		}
 		sg.MergeResult(comprop, true)
+		if comprop.GenSchema.IsBaseType {
+			hasBaseType = true
+		} else if inherited == -1 && sch.Ref.String() != "" && tpe.IsComplexObject && !tpe.IsArray && !tpe.IsMap {
+			inherited = len(sg.GenSchema.AllOf)
+		}
 		sg.GenSchema.AllOf = append(sg.GenSchema.AllOf, comprop.GenSchema)
 	}
+	if !hasBaseType && inherited != -1 {
+		// allOf: [{$ref: Base}, {properties: ...}] extends Base with the properties of the other branches
+		sg.GenSchema.AllOf[inherited].IsBaseType = true
+	}
 	if hasArray > 1 || (hasArray > 0 && hasNonArray > 0) {
-		log.Printf("warning: cannot generate serializable allOf with conflicting array definitions in %s", sg.Container)
+		sg.warnings.unsupported(sg.TypeResolver.ModelName, "cannot generate serializable allOf with conflicting array definitions in %s", sg.Container)
+	}
+	return sg.warnAllOfConflicts()
+}
+
+// buildOneOf builds the types of the union a oneOf schema is rendered as
+func (sg *schemaGenContext) buildOneOf() error {
+	union, err := sg.buildUnion("oneOf", sg.Schema.OneOf)
+	sg.GenSchema.OneOf = union
+	return err
+}
+
+// buildAnyOf builds the types of the union an anyOf schema is rendered as. The union is permissive, since it
+// doesn't tell that the value may match several types, unlike anyOf.
+func (sg *schemaGenContext) buildAnyOf() error {
+	if len(sg.GenSchema.OneOf) > 0 {
+		return nil
+	}
+	union, err := sg.buildUnion("anyOf", sg.Schema.AnyOf)
+	sg.GenSchema.AnyOf = union
+	return err
+}
+
+// buildUnion builds the types of the union the branches of a oneOf or an anyOf are rendered as. The anonymous
+// objects of the branches are generated as new schemas, like the anonymous objects of the properties.
+//
+// A oneOf or anyOf which only constrains the properties of an object, such as oneOf: [{required: [a]}, {required: [b]}],
+// is not a union and is left out, as well as the one of a schema typed by its format or its extensions, such as the
+// anyOf: [{type: integer}, {type: string}] of an x-kubernetes-int-or-string schema.
+func (sg *schemaGenContext) buildUnion(keyword string, branches []spec.Schema) (GenSchemaList, error) {
+	if len(branches) == 0 || len(sg.Schema.Properties) > 0 {
+		return nil, nil
+	}
+	if typed, _, err := sg.TypeResolver.resolveFormat(&sg.Schema, true, false); err != nil || typed {
+		return nil, err
+	}
+	if typed, _, err := sg.TypeResolver.resolveExtensions(&sg.Schema, true, false); err != nil || typed {
+		return nil, err
+	}
+	for _, sch := range branches {
+		if sch.Ref.String() == "" && len(sch.Type) == 0 && len(sch.Properties) == 0 && sch.Items == nil && len(sch.OneOf) == 0 && len(sch.AnyOf) == 0 {
+			debugLog("the %s of %s constrains its value without typing it, it is not rendered as a union", keyword, sg.Name)
+			return nil, nil
+		}
+	}
+	var union GenSchemaList
+	seen := make(map[string]bool, len(branches))
+	var primitives, others []string
+	for i, sch := range branches {
+		tpe, err := sg.TypeResolver.ResolveSchema(&sch, sch.Ref.String() == "", false)
+		if err != nil {
+			return nil, err
+		}
+		if tpe.IsComplexObject && tpe.IsAnonymous {
+			ng := sg.makeAnonSchema(sg.Name, sg.Name+" "+keyword+strconv.Itoa(i), sch)
+			ng.SourcePath = sg.SourcePath + "/" + keyword + "/" + strconv.Itoa(i)
+			if err := ng.makeGenSchema(); err != nil {
+				return nil, err
+			}
+			sg.MergeResult(ng, false)
+			sg.ExtraSchemas[ng.Name] = ng.GenSchema
+			sch = *spec.RefProperty("#/definitions/" + ng.Name)
+		}
+		pg := sg.NewBranch(sch)
+		if err := pg.makeGenSchema(); err != nil {
+			return nil, err
+		}
+		sg.MergeResult(pg, false)
+		if seen[pg.GenSchema.KclType] {
+			continue
+		}
+		seen[pg.GenSchema.KclType] = true
+		if pg.GenSchema.IsPrimitive {
+			primitives = append(primitives, pg.GenSchema.KclType)
+		} else {
+			others = append(others, pg.GenSchema.KclType)
+		}
+		union = append(union, pg.GenSchema)
+	}
+	if len(primitives) > 0 && len(others) > 0 {
+		sg.warnings.warn(sg.TypeResolver.ModelName, "the %s branches of %s mix the primitive types %s with the types %s, the union is generated anyway",
+			keyword, sg.Name, strings.Join(primitives, ", "), strings.Join(others, ", "))
+	}
+	return union, nil
+}
+
+// unionType returns the union of the types of the schemas, e.g. Cat | Dog
+func unionType(schemas GenSchemaList) string {
+	types := make([]string, 0, len(schemas))
+	for _, sch := range schemas {
+		types = append(types, sch.KclType)
+	}
+	return strings.Join(types, " | ")
+}
+
+// warnAllOfConflicts warns about the properties defined with incompatible types in several
+// allOf branches, since these definitions can't be honored all together.
+func (sg *schemaGenContext) warnAllOfConflicts() error {
+	seen := make(map[string]string)
+	for _, branch := range sg.Schema.AllOf {
+		sch := &branch
+		for sch.Ref.String() != "" {
+			rsch, _, err := resolveRef(sg.TypeResolver.Doc.Spec(), sch.Ref)
+			if err != nil {
+				return err
+			}
+			sch = rsch
+		}
+		names := make([]string, 0, len(sch.Properties))
+		for name := range sch.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop := sch.Properties[name]
+			tpe, err := sg.TypeResolver.ResolveSchema(&prop, true, false)
+			if err != nil {
+				return err
+			}
+			if prev, ok := seen[name]; ok {
+				if prev != tpe.KclType {
+					sg.warnings.warn(sg.TypeResolver.ModelName, "the property %s is defined with conflicting types in the allOf branches of %s: %s and %s", name, sg.Container, prev, tpe.KclType)
+				}
+				continue
+			}
+			seen[name] = tpe.KclType
+		}
 	}
 	return nil
 }
 
+// inheritedProperty is a property of the schema inherited by an allOf composition
+type inheritedProperty struct {
+	KclType  string
+	Required bool
+}
+
+// flattenAllOf merges the properties of the inline objects of the allOf branches into the properties of the schema,
+// so that allOf: [{$ref: Base}, {properties: ...}] is rendered as a schema inheriting Base with the properties of
+// the inline object as its own attributes. A property defined several times is merged into the first definition,
+// which is required if any of them is. A property which redefines an inherited one with the same type and without
+// any constraint of its own is left out. The subtypes of a discriminator are deduplicated along with their base.
+func (sg *schemaGenContext) flattenAllOf() error {
+	if len(sg.GenSchema.AllOf) == 0 {
+		return nil
+	}
+	if sg.Named && sg.Discrimination != nil {
+		if _, ok := sg.Discrimination.Discriminated["#/definitions/"+sg.Name]; ok {
+			return nil
+		}
+	}
+	inherited, err := sg.inheritedProperties()
+	if err != nil {
+		return err
+	}
+	var properties GenSchemaList
+	index := make(map[string]int)
+	add := func(prop GenSchema) {
+		if i, ok := index[prop.Name]; ok {
+			properties[i].Required = properties[i].Required || prop.Required
+			return
+		}
+		if base, ok := inherited[prop.Name]; ok && base.KclType == prop.KclType && (base.Required || !prop.Required) &&
+			!prop.HasValidations && len(prop.Enum) == 0 && prop.Default == nil {
+			debugLog("dropping the property %s[%q] inherited from the allOf base", sg.Name, prop.Name)
+			return
+		}
+		index[prop.Name] = len(properties)
+		properties = append(properties, prop)
+	}
+	flattened := false
+	for i := range sg.GenSchema.AllOf {
+		branch := &sg.GenSchema.AllOf[i]
+		if branch.IsBaseType || len(branch.Properties) == 0 {
+			continue
+		}
+		for _, prop := range branch.Properties {
+			add(prop)
+		}
+		branch.Properties = nil
+		flattened = true
+	}
+	if !flattened {
+		return nil
+	}
+	for _, prop := range sg.GenSchema.Properties {
+		add(prop)
+	}
+	sg.GenSchema.Properties = properties
+	sg.disambiguatePropertyNames()
+	return nil
+}
+
+// inheritedProperties returns the properties of the schemas inherited by the allOf composition of the schema,
+// including the ones they inherit themselves
+func (sg *schemaGenContext) inheritedProperties() (map[string]inheritedProperty, error) {
+	inherited := make(map[string]inheritedProperty)
+	if len(sg.Schema.AllOf) != len(sg.GenSchema.AllOf) {
+		return inherited, nil
+	}
+	visited := make(map[string]struct{})
+	var collect func(sch *spec.Schema) error
+	collect = func(sch *spec.Schema) error {
+		for sch.Ref.String() != "" {
+			if _, ok := visited[sch.Ref.String()]; ok {
+				return nil
+			}
+			visited[sch.Ref.String()] = struct{}{}
+			rsch, _, err := resolveRef(sg.TypeResolver.Doc.Spec(), sch.Ref)
+			if err != nil {
+				return err
+			}
+			sch = rsch
+		}
+		for name, prop := range sch.Properties {
+			prop := prop
+			tpe, err := sg.TypeResolver.ResolveSchema(&prop, true, false)
+			if err != nil {
+				return err
+			}
+			inherited[name] = inheritedProperty{
+				KclType:  tpe.KclType,
+				Required: swag.ContainsStrings(sch.Required, name) || inherited[name].Required,
+			}
+		}
+		for i := range sch.AllOf {
+			if err := collect(&sch.AllOf[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i := range sg.GenSchema.AllOf {
+		if !sg.GenSchema.AllOf[i].IsBaseType {
+			continue
+		}
+		if err := collect(&sg.Schema.AllOf[i]); err != nil {
+			return nil, err
+		}
+	}
+	return inherited, nil
+}
+
 type mapStack struct {
 	Type     *spec.Schema
 	Next     *mapStack
@@ -805,7 +1365,8 @@ func newMapStack(context *schemaGenContext) (first, last *mapStack, err error) {
 			//reached the end of the rabbit hole
 			if tpe.IsComplexObject && tpe.IsAnonymous {
 				// found an anonymous object: create the struct from a newly created definition
-				nw := l.Context.makeNewSchema(l.Context.Name+" Anon", *l.Type.AdditionalProperties.Schema)
+				nw := l.Context.makeAnonSchema(l.Context.Name, l.Context.Name+" Anon", *l.Type.AdditionalProperties.Schema)
+				nw.SourcePath = l.Context.SourcePath + "/additionalProperties"
 				sch := spec.RefProperty("#/definitions/" + nw.Name)
 				l.NewObj = nw
 
@@ -933,7 +1494,12 @@ func (mt *mapStack) HasMore() bool {
 
 func (sg *schemaGenContext) buildAdditionalProperties() error {
 	if sg.Schema.AdditionalProperties == nil {
-		return nil
+		if len(sg.Schema.Properties) == 0 || !preservesUnknownFields(&sg.Schema) {
+			return nil
+		}
+		// the unknown fields of a kubernetes object are additional properties of any type,
+		// an object without properties being already rendered as any
+		sg.Schema.AdditionalProperties = &spec.SchemaOrBool{Allows: true, Schema: new(spec.Schema)}
 	}
 	addp := *sg.Schema.AdditionalProperties
 
@@ -961,9 +1527,8 @@ func (sg *schemaGenContext) buildAdditionalProperties() error {
 			sg.GenSchema.IsComplexObject = false
 			sg.GenSchema.IsMap = true
 
-			sg.GenSchema.ValueExpression += "." + swag.ToGoName(sg.Name+" additionalProperties")
 			cp := sg.NewAdditionalProperty(*addp.Schema)
-			cp.Name += "AdditionalProperties"
+			cp.Name = sg.additionalPropsName()
 			cp.Required = false
 			if err := cp.makeGenSchema(); err != nil {
 				return err
@@ -985,7 +1550,8 @@ func (sg *schemaGenContext) buildAdditionalProperties() error {
 
 		if tpe.IsComplexObject && tpe.IsAnonymous {
 			// if the AdditionalProperties is an anonymous complex object, generate a new type for it
-			pg := sg.makeNewSchema(sg.Name+" Anon", *addp.Schema)
+			pg := sg.makeAnonSchema(sg.Name, sg.Name+" Anon", *addp.Schema)
+			pg.SourcePath = sg.SourcePath + "/additionalProperties"
 			if err := pg.makeGenSchema(); err != nil {
 				return err
 			}
@@ -1003,11 +1569,8 @@ func (sg *schemaGenContext) buildAdditionalProperties() error {
 			comprop.GenSchema.Required = true
 			comprop.GenSchema.HasValidations = true
 
-			comprop.GenSchema.ValueExpression = sg.GenSchema.ValueExpression + "." + swag.ToGoName(sg.GenSchema.Name) + "[" + comprop.KeyVar + "]"
-
 			sg.GenSchema.AdditionalProperties = &comprop.GenSchema
 			sg.GenSchema.HasAdditionalProperties = true
-			sg.GenSchema.ValueExpression += "." + swag.ToGoName(sg.GenSchema.Name)
 
 			sg.MergeResult(comprop, false)
 
@@ -1015,7 +1578,6 @@ func (sg *schemaGenContext) buildAdditionalProperties() error {
 		}
 
 		// this is a regular named schema for AdditionalProperties
-		sg.GenSchema.ValueExpression += "." + swag.ToGoName(sg.GenSchema.Name)
 		comprop := sg.NewAdditionalProperty(*addp.Schema)
 		d := sg.TypeResolver.Doc
 		asch, err := analysis.Schema(analysis.SchemaOpts{
@@ -1033,18 +1595,6 @@ func (sg *schemaGenContext) buildAdditionalProperties() error {
 
 		sg.MergeResult(comprop, false)
 		sg.GenSchema.AdditionalProperties = &comprop.GenSchema
-		sg.GenSchema.AdditionalProperties.ValueExpression = sg.GenSchema.ValueExpression + "[" + comprop.KeyVar + "]"
-
-		// rewrite value expression for arrays and arrays of arrays in maps (rendered as map[string][][]...)
-		if sg.GenSchema.AdditionalProperties.IsArray {
-			// maps of slices are where an override may take effect
-			sg.GenSchema.AdditionalProperties.Items.ValueExpression = sg.GenSchema.ValueExpression + "[" + comprop.KeyVar + "]" + "[" + sg.GenSchema.AdditionalProperties.IndexVar + "]"
-			ap := sg.GenSchema.AdditionalProperties.Items
-			for ap != nil && ap.IsArray {
-				ap.Items.ValueExpression = ap.ValueExpression + "[" + ap.IndexVar + "]"
-				ap = ap.Items
-			}
-		}
 		return nil
 	}
 
@@ -1067,7 +1617,7 @@ func (sg *schemaGenContext) buildAdditionalProperties() error {
 		// for an anonymous object, first build the new object
 		// and then replace the current one with a $ref to the
 		// new object
-		newObj := sg.makeNewSchema(sg.GenSchema.Name+" P"+strconv.Itoa(sg.Index), sg.Schema)
+		newObj := sg.makeAnonSchema(sg.GenSchema.Name, sg.GenSchema.Name+" P"+strconv.Itoa(sg.Index), sg.Schema)
 		if err := newObj.makeGenSchema(); err != nil {
 			return err
 		}
@@ -1086,13 +1636,71 @@ func (sg *schemaGenContext) buildAdditionalProperties() error {
 	return nil
 }
 
+// additionalPropsName returns the name of the values of the additional properties of an object allowing
+// any additional property: the name of the object followed by the configured suffix. A warning is raised
+// when the name is the name of a property of the model.
+func (sg *schemaGenContext) additionalPropsName() string {
+	suffix := sg.AdditionalPropsName
+	if suffix == "" {
+		suffix = DefaultAdditionalPropsName
+	}
+	name := sg.Name + suffix
+	if model, ok := sg.TypeResolver.Doc.Spec().Definitions[sg.TypeResolver.ModelName]; ok {
+		if _, clash := model.Properties[name]; clash {
+			sg.warnings.warn(sg.TypeResolver.ModelName, "the name %s of the additional properties of %s is the name of a property of %s, "+
+				"set another additional properties name to tell them apart", name, sg.Name, sg.TypeResolver.ModelName)
+		}
+	}
+	return name
+}
+
 func (sg *schemaGenContext) makeNewSchema(name string, schema spec.Schema) *schemaGenContext {
 	debugLog("making new schema: name: %s, container: %s", name, sg.Container)
-	sp := sg.TypeResolver.Doc.Spec()
 	name = swag.ToGoName(name)
 	if sg.TypeResolver.ModelName != sg.Name {
 		name = swag.ToGoName(sg.TypeResolver.ModelName + " " + name)
 	}
+	return sg.newSchema(name, schema)
+}
+
+// makeAnonSchema makes a new schema for an anonymous object nested in the property prop, named according to the
+// naming strategy. The name given by the concat strategy is relative to the model, like the name of makeNewSchema.
+func (sg *schemaGenContext) makeAnonSchema(prop, name string, schema spec.Schema) *schemaGenContext {
+	switch sg.AnonNaming {
+	case AnonNamingShort:
+		return sg.newSchema(sg.uniqueDefinitionName(swag.ToGoName(prop)), schema)
+	case AnonNamingHash:
+		return sg.newSchema(sg.uniqueDefinitionName("Anon"+schemaHash(schema)), schema)
+	}
+	return sg.makeNewSchema(name, schema)
+}
+
+// uniqueDefinitionName suffixes the name with a number when it is already taken by a definition of the spec,
+// including the new schemas made for the anonymous objects
+func (sg *schemaGenContext) uniqueDefinitionName(name string) string {
+	definitions := sg.TypeResolver.Doc.Spec().Definitions
+	unique := name
+	for i := 1; ; i++ {
+		if _, ok := definitions[unique]; !ok {
+			return unique
+		}
+		unique = name + strconv.Itoa(i)
+	}
+}
+
+// schemaHash returns a short hash of the content of a schema
+func schemaHash(schema spec.Schema) string {
+	content, err := json.Marshal(schema)
+	if err != nil {
+		content = []byte(fmt.Sprint(schema))
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:4])
+}
+
+// newSchema makes a new schema, which is added to the definitions of the spec
+func (sg *schemaGenContext) newSchema(name string, schema spec.Schema) *schemaGenContext {
+	sp := sg.TypeResolver.Doc.Spec()
 	if sp.Definitions == nil {
 		sp.Definitions = make(spec.Definitions)
 	}
@@ -1100,9 +1708,6 @@ func (sg *schemaGenContext) makeNewSchema(name string, schema spec.Schema) *sche
 	pg := schemaGenContext{
 		Path:                       "",
 		Name:                       name,
-		Receiver:                   sg.Receiver,
-		IndexVar:                   "i",
-		ValueExpr:                  sg.Receiver,
 		Schema:                     schema,
 		Required:                   false,
 		Named:                      true,
@@ -1111,6 +1716,21 @@ func (sg *schemaGenContext) makeNewSchema(name string, schema spec.Schema) *sche
 		Container:                  sg.Container,
 		StrictAdditionalProperties: sg.StrictAdditionalProperties,
 		KeepOrder:                  sg.KeepOrder,
+		SourceComments:             sg.SourceComments,
+		SourcePath:                 sg.SourcePath,
+		RequireOptionalChecks:      sg.RequireOptionalChecks,
+		ReadOnlyOptional:           sg.ReadOnlyOptional,
+		DropReadOnly:               sg.DropReadOnly,
+		ExcludeWriteOnly:           sg.ExcludeWriteOnly,
+		WithExamples:               sg.WithExamples,
+		FormatRangeChecks:          sg.FormatRangeChecks,
+		DateAsStrCheck:             sg.DateAsStrCheck,
+		FormatPatternChecks:        sg.FormatPatternChecks,
+		NonEmptyStrings:            sg.NonEmptyStrings,
+		StrictEnumTypes:            sg.StrictEnumTypes,
+		AnonNaming:                 sg.AnonNaming,
+		AdditionalPropsName:        sg.AdditionalPropsName,
+		warnings:                   sg.warnings,
 	}
 	if schema.Ref.String() == "" {
 		pg.TypeResolver = sg.TypeResolver.NewWithModelName(name)
@@ -1127,7 +1747,8 @@ func (sg *schemaGenContext) buildArray() error {
 
 	// check if the element is a complex object, if so generate a new type for it
 	if tpe.IsComplexObject && tpe.IsAnonymous {
-		pg := sg.makeNewSchema(sg.Name+" items"+strconv.Itoa(sg.Index), *sg.Schema.Items.Schema)
+		pg := sg.makeAnonSchema(sg.Name, sg.Name+" items"+strconv.Itoa(sg.Index), *sg.Schema.Items.Schema)
+		pg.SourcePath = sg.SourcePath + "/items"
 		if err := pg.makeGenSchema(); err != nil {
 			return err
 		}
@@ -1148,6 +1769,14 @@ func (sg *schemaGenContext) buildArray() error {
 	sg.MergeResult(elProp, false)
 	sg.GenSchema.IsBaseType = elProp.GenSchema.IsBaseType
 	sg.GenSchema.ItemsEnum = elProp.GenSchema.Enum
+	if def, ok := sg.GenSchema.Default.([]interface{}); ok && len(sg.GenSchema.ItemsEnum) > 0 {
+		// the items of the default render as members of the enum literal union of the items
+		items := make([]interface{}, len(def))
+		for i, item := range def {
+			items[i] = elProp.enumDefault(item)
+		}
+		sg.GenSchema.Default = items
+	}
 	elProp.GenSchema.Suffix = "Items"
 	sg.GenSchema.KclType = "[" + elProp.GenSchema.KclType + "]"
 	sg.GenSchema.IsArray = true
@@ -1156,7 +1785,9 @@ func (sg *schemaGenContext) buildArray() error {
 
 	// validations of items
 	// include format validation
-	schemaCopy.HasValidations = hasValidations(sg.Schema.Items.Schema)
+	items := *sg.Schema.Items.Schema
+	sg.applyFormatChecks(&items)
+	schemaCopy.HasValidations = hasValidations(&items) || hasTranslatableRules(schemaCopy.Rules)
 
 	// lift validations
 	sg.GenSchema.HasValidations = sg.GenSchema.HasValidations || schemaCopy.HasValidations
@@ -1184,7 +1815,7 @@ func (sg *schemaGenContext) buildItems() error {
 	// This is a tuple, build a new model that represents this
 	if sg.Named {
 		sg.GenSchema.Name = sg.Name
-		sg.GenSchema.EscapedName = DefaultLanguageFunc().MangleModelName(sg.GenSchema.Name)
+		sg.GenSchema.EscapedName = DefaultLanguageFunc().mangleModelName(sg.GenSchema.Name, sg.warnings)
 		sg.GenSchema.KclType = sg.TypeResolver.kclTypeName(sg.Name)
 		for i, s := range sg.Schema.Items.Schemas {
 			elProp := sg.NewTupleElement(&s, i)
@@ -1196,7 +1827,8 @@ func (sg *schemaGenContext) buildItems() error {
 				}
 				if tpe.IsComplexObject && tpe.IsAnonymous {
 					// if the tuple element is an anonymous complex object, build a new type for it
-					pg := sg.makeNewSchema(sg.Name+" Items"+strconv.Itoa(i), s)
+					pg := sg.makeAnonSchema(sg.Name, sg.Name+" Items"+strconv.Itoa(i), s)
+					pg.SourcePath = sg.SourcePath + "/items/" + strconv.Itoa(i)
 					if err := pg.makeGenSchema(); err != nil {
 						return err
 					}
@@ -1211,13 +1843,17 @@ func (sg *schemaGenContext) buildItems() error {
 			}
 			sg.MergeResult(elProp, false)
 			elProp.GenSchema.Name = "p" + strconv.Itoa(i)
-			elProp.GenSchema.EscapedName = DefaultLanguageFunc().MangleModelName(elProp.GenSchema.Name)
+			elProp.GenSchema.EscapedName = DefaultLanguageFunc().mangleModelName(elProp.GenSchema.Name, sg.warnings)
 			sg.GenSchema.Properties = append(sg.GenSchema.Properties, elProp.GenSchema)
 			sg.GenSchema.IsTuple = true
 		}
 		return nil
 	}
 
+	if disallowsAdditionalItems(&sg.Schema) {
+		return sg.buildFixedLengthTuple()
+	}
+
 	// for an anonymous object, first build the new object
 	// and then replace the current one with a $ref to the
 	// new tuple object
@@ -1249,6 +1885,186 @@ func (sg *schemaGenContext) buildItems() error {
 	return nil
 }
 
+// enumDefault returns the enum value a default value stands for, so that the default renders
+// exactly as a member of the enum literal union, e.g. the default 1 of the enum ["1", "2"]
+// becomes "1". A default which is not one of the enum values is kept and warned about.
+func (sg *schemaGenContext) enumDefault(def interface{}) interface{} {
+	if def == nil || len(sg.GenSchema.Enum) == 0 {
+		return def
+	}
+	lang := DefaultLanguageFunc()
+	rendered := lang.ToKclValue(def)
+	for _, e := range sg.GenSchema.Enum {
+		if lang.ToKclValue(e) == rendered {
+			return e
+		}
+	}
+	for _, e := range sg.GenSchema.Enum {
+		if fmt.Sprint(e) == fmt.Sprint(def) {
+			debugLog("the default value %s of %s is converted to the enum value %s", rendered, sg.Name, lang.ToKclValue(e))
+			return e
+		}
+	}
+	sg.warnings.warn(sg.TypeResolver.ModelName, "the default value %s of %s is not one of its enum values %s", rendered, sg.Name, lang.ToKclValue(sg.GenSchema.Enum))
+	return def
+}
+
+// checkEnumTypes reports the enum values which do not match the type of the schema, such as the string "1"
+// of an integer enum, since they turn the enum into a union of mismatched literals. The mismatches fail the
+// generation in the strict enum types mode, and are warned about otherwise.
+func (sg *schemaGenContext) checkEnumTypes() error {
+	if len(sg.Schema.Type) == 0 {
+		return nil
+	}
+	var mismatches []interface{}
+	for _, e := range sg.GenSchema.Enum {
+		if !enumValueMatches(e, sg.Schema.Type) {
+			mismatches = append(mismatches, e)
+		}
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+	lang := DefaultLanguageFunc()
+	tpe := strings.Join(sg.Schema.Type, " | ")
+	if sg.StrictEnumTypes {
+		return fmt.Errorf("the enum values %s of %s do not match its type %s", lang.ToKclValue(mismatches), sg.Name, tpe)
+	}
+	sg.warnings.warn(sg.TypeResolver.ModelName, "the enum values %s of %s do not match its type %s", lang.ToKclValue(mismatches), sg.Name, tpe)
+	return nil
+}
+
+// enumValueMatches tells whether an enum value is of one of the types, an integral number being an integer
+func enumValueMatches(value interface{}, types spec.StringOrArray) bool {
+	for _, tpe := range types {
+		switch tpe {
+		case str:
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case boolean:
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case integer:
+			switch v := value.(type) {
+			case int, int64, uint64:
+				return true
+			case float64:
+				if v == math.Trunc(v) {
+					return true
+				}
+			}
+		case number:
+			switch value.(type) {
+			case int, int64, uint64, float32, float64:
+				return true
+			}
+		case object, array:
+			// the complex enum values are reported by pruneEnums
+			return true
+		}
+	}
+	return false
+}
+
+// enumType returns the literal union of the enum values, e.g. "a" | "b" | "c"
+func enumType(enum []interface{}) string {
+	lang := DefaultLanguageFunc()
+	members := make([]string, 0, len(enum))
+	for _, e := range enum {
+		members = append(members, lang.ToKclValue(e))
+	}
+	return strings.Join(members, " | ")
+}
+
+// pinBooleanEnum replaces the enum of a boolean by the bool type: when both values are allowed the
+// enum is dropped, and when only one is, the value is pinned with a check and used as the default.
+func (sg *schemaGenContext) pinBooleanEnum() {
+	if len(sg.GenSchema.Enum) == 0 || !sg.Schema.Type.Contains(boolean) {
+		return
+	}
+	values := make(map[bool]struct{}, 2)
+	for _, e := range sg.GenSchema.Enum {
+		value, ok := e.(bool)
+		if !ok {
+			return
+		}
+		values[value] = struct{}{}
+	}
+	sg.GenSchema.Enum = nil
+	if len(values) != 1 {
+		return
+	}
+	for value := range values {
+		sg.GenSchema.PinnedValue = DefaultLanguageFunc().ToKclValue(value)
+		if sg.GenSchema.Default == nil {
+			sg.GenSchema.Default = value
+		}
+	}
+	sg.GenSchema.HasValidations = true
+}
+
+// pinReadOnlyDefault checks a read-only property to keep its default, which the clients can't change.
+// The default is assigned when the value is not set, and the value is checked to be equal to it,
+// e.g. version?: str = "v1" with the check version == "v1". A single-valued enum, such as a const, is already
+// pinned by its literal type.
+func (sg *schemaGenContext) pinReadOnlyDefault() {
+	if !sg.GenSchema.ReadOnly || sg.GenSchema.PinnedValue != "" || sg.GenSchema.IsComplexObject ||
+		sg.GenSchema.Default == nil || len(sg.GenSchema.Enum) == 1 {
+		return
+	}
+	sg.GenSchema.PinnedValue = DefaultLanguageFunc().ToKclValue(sg.GenSchema.Default)
+	sg.GenSchema.HasValidations = true
+}
+
+// disallowsAdditionalItems tells if a tuple schema is closed with "additionalItems: false"
+func disallowsAdditionalItems(sch *spec.Schema) bool {
+	return sch.AdditionalItems != nil && !sch.AdditionalItems.Allows && sch.AdditionalItems.Schema == nil
+}
+
+// buildFixedLengthTuple renders an anonymous tuple which disallows additional items
+// as a list of the union of its element types, checked to have exactly the tuple length.
+func (sg *schemaGenContext) buildFixedLengthTuple() error {
+	var elemTypes []string
+	for i, s := range sg.Schema.Items.Schemas {
+		elProp := sg.NewTupleElement(&s, i)
+		if s.Ref.String() == "" {
+			tpe, err := sg.TypeResolver.ResolveSchema(&s, true, true)
+			if err != nil {
+				return err
+			}
+			if tpe.IsComplexObject && tpe.IsAnonymous {
+				// if the tuple element is an anonymous complex object, build a new type for it
+				pg := sg.makeAnonSchema(sg.Name, sg.Name+" Items"+strconv.Itoa(i), s)
+				pg.SourcePath = sg.SourcePath + "/items/" + strconv.Itoa(i)
+				if err := pg.makeGenSchema(); err != nil {
+					return err
+				}
+				elProp.Schema = *spec.RefProperty("#/definitions/" + pg.Name)
+				elProp.MergeResult(pg, false)
+				elProp.ExtraSchemas[pg.Name] = pg.GenSchema
+			}
+		}
+		if err := elProp.makeGenSchema(); err != nil {
+			return err
+		}
+		sg.MergeResult(elProp, false)
+		if !swag.ContainsStrings(elemTypes, elProp.GenSchema.KclType) {
+			elemTypes = append(elemTypes, elProp.GenSchema.KclType)
+		}
+	}
+	length := int64(len(sg.Schema.Items.Schemas))
+	sg.GenSchema.KclType = "[" + strings.Join(elemTypes, " | ") + "]"
+	sg.GenSchema.IsArray = true
+	sg.GenSchema.IsTuple = false
+	sg.GenSchema.IsComplexObject = false
+	sg.GenSchema.TupleLength = &length
+	sg.GenSchema.HasValidations = true
+	sg.GenSchema.HasSliceValidations = true
+	return nil
+}
+
 func (sg *schemaGenContext) buildAdditionalItems() error {
 	wantsAdditionalItems :=
 		sg.Schema.AdditionalItems != nil &&
@@ -1262,7 +2078,8 @@ func (sg *schemaGenContext) buildAdditionalItems() error {
 			return err
 		}
 		if tpe.IsComplexObject && tpe.IsAnonymous {
-			pg := sg.makeNewSchema(sg.Name+" Items", *sg.Schema.AdditionalItems.Schema)
+			pg := sg.makeAnonSchema(sg.Name, sg.Name+" Items", *sg.Schema.AdditionalItems.Schema)
+			pg.SourcePath = sg.SourcePath + "/additionalItems"
 			if err := pg.makeGenSchema(); err != nil {
 				return err
 			}
@@ -1273,10 +2090,6 @@ func (sg *schemaGenContext) buildAdditionalItems() error {
 		}
 
 		it := sg.NewAdditionalItems(sg.Schema.AdditionalItems.Schema)
-		// if AdditionalItems are themselves arrays, bump the index var
-		if tpe.IsArray {
-			it.IndexVar += "i"
-		}
 
 		if err := it.makeGenSchema(); err != nil {
 			return err
@@ -1343,7 +2156,8 @@ func (sg *schemaGenContext) shortCircuitNamedRef() (bool, error) {
 		tpe.IsComplexObject = false
 		tpe.IsAnonymous = false
 		tpe.IsBaseType = tpx.IsBaseType
-		tpe.KclType = sg.TypeResolver.kclTypeName(path.Base(sg.Schema.Ref.String()))
+		ref := normalizeRef(sg.Schema.Ref)
+		tpe.KclType = sg.TypeResolver.kclTypeName(path.Base(ref.String()))
 		tpe.SwaggerType = tpx.SwaggerType
 		sch := spec.Schema{}
 		pg := sg.makeNewSchema(sg.Name, sch)
@@ -1428,30 +2242,62 @@ func (sg *schemaGenContext) makeGenSchema() error {
 		!sg.Named, sg.Required, sg.IsTuple, sg.Name, sg.Schema)
 	sg.GenSchema.IsExported = true
 	sg.GenSchema.Path = sg.Path
-	sg.GenSchema.IndexVar = sg.IndexVar
-	sg.GenSchema.ValueExpression = sg.ValueExpr
-	sg.GenSchema.KeyVar = sg.KeyVar
 	sg.GenSchema.OriginalName = sg.Name
 	sg.GenSchema.Name = sg.KclName()
-	sg.GenSchema.EscapedName = DefaultLanguageFunc().MangleModelName(sg.GenSchema.Name)
+	if sg.Named {
+		sg.GenSchema.EscapedName = DefaultLanguageFunc().mangleModelName(sg.GenSchema.Name, sg.warnings)
+	} else {
+		sg.GenSchema.EscapedName = DefaultLanguageFunc().manglePropertyName(sg.GenSchema.Name, sg.warnings)
+	}
 	sg.GenSchema.Title = sg.Schema.Title
 	sg.GenSchema.Description = trimBOM(sg.Schema.Description)
-	sg.GenSchema.ReceiverName = sg.Receiver
-	sg.GenSchema.sharedValidations = sg.schemaValidations()
+	sg.markQuantity()
+	validations, err := sg.schemaValidations()
+	if err != nil {
+		return err
+	}
+	sg.GenSchema.sharedValidations = validations
 	sg.GenSchema.ReadOnly = sg.Schema.ReadOnly
+	sg.GenSchema.WriteOnly = isWriteOnly(&sg.Schema)
+	sg.GenSchema.Deprecated, sg.GenSchema.DeprecatedReason = deprecation(&sg.Schema)
+	sg.GenSchema.PrinterColumn = printerColumn(&sg.Schema)
+	sg.GenSchema.Mixins = kclMixins(&sg.Schema)
+	sg.GenSchema.Rules = kubernetesValidations(&sg.Schema)
+	if hasTranslatableRules(sg.GenSchema.Rules) {
+		sg.GenSchema.HasValidations = true
+	}
 	sg.GenSchema.StrictAdditionalProperties = sg.StrictAdditionalProperties
-	sg.GenSchema.Required = sg.Required
+	// a null member of the enum allows the value to be None
+	sg.GenSchema.Required = sg.Required && !sg.GenSchema.EnumAllowsNone
+	sg.GenSchema.RequireOptionalChecks = sg.RequireOptionalChecks
 	sg.GenSchema.ExternalDocs = sg.Schema.ExternalDocs
+	if sg.SourceComments {
+		sg.GenSchema.SourcePath = sg.SourcePath
+	}
 
 	if sg.KeepOrder {
-		sg.GenSchema.Default = RecoverMapValueOrder(sg.Schema.Default)
-		sg.GenSchema.Example = RecoverMapValueOrder(sg.Schema.Example)
+		if sg.GenSchema.Default, err = RecoverMapValueOrder(sg.Schema.Default); err != nil {
+			return fmt.Errorf("the default of %s in model <%s>: %v", sg.Name, sg.TypeResolver.ModelName, err)
+		}
+		if sg.GenSchema.Example, err = RecoverMapValueOrder(sg.Schema.Example); err != nil {
+			return fmt.Errorf("the example of %s in model <%s>: %v", sg.Name, sg.TypeResolver.ModelName, err)
+		}
 	} else {
 		sg.GenSchema.Default = sg.Schema.Default
 		sg.GenSchema.Example = sg.Schema.Example
 	}
+	if sg.WithExamples {
+		if sg.GenSchema.Examples, err = namedExamples(sg.Schema.Extensions[xExamples], sg.KeepOrder); err != nil {
+			return fmt.Errorf("the examples of %s in model <%s>: %v", sg.Name, sg.TypeResolver.ModelName, err)
+		}
+	}
+	if err := sg.checkEnumTypes(); err != nil {
+		return err
+	}
+	sg.GenSchema.Default = sg.enumDefault(sg.GenSchema.Default)
+	sg.pinBooleanEnum()
+	sg.pinReadOnlyDefault()
 
-	var err error
 	returns, err := sg.shortCircuitNamedRef()
 	if err != nil {
 		return err
@@ -1472,6 +2318,12 @@ func (sg *schemaGenContext) makeGenSchema() error {
 	if e := sg.buildAllOf(); e != nil {
 		return e
 	}
+	if e := sg.buildOneOf(); e != nil {
+		return e
+	}
+	if e := sg.buildAnyOf(); e != nil {
+		return e
+	}
 
 	var tpe resolvedType
 	tpe, err = sg.TypeResolver.ResolveSchema(&sg.Schema, !sg.Named, sg.IsTuple || sg.Required || sg.GenSchema.Required)
@@ -1486,13 +2338,6 @@ func (sg *schemaGenContext) makeGenSchema() error {
 		return e
 	}
 
-	// rewrite value expression from top-down
-	cur := &sg.GenSchema
-	for cur.AdditionalProperties != nil {
-		cur.AdditionalProperties.ValueExpression = cur.ValueExpression + "[" + cur.AdditionalProperties.KeyVar + "]"
-		cur = cur.AdditionalProperties
-	}
-
 	prev := sg.GenSchema
 	debugLogAsJSON("typed resolve, isAnonymous(%t), n: %t, t: %t, sgr: %t, sr: %t, isRequired(%t), BaseType(%t)",
 		!sg.Named, sg.Named, sg.IsTuple, sg.Required, sg.GenSchema.Required,
@@ -1506,11 +2351,19 @@ func (sg *schemaGenContext) makeGenSchema() error {
 	sg.GenSchema.IsMap = prev.IsMap
 	sg.GenSchema.IsAdditionalProperties = prev.IsAdditionalProperties
 	sg.GenSchema.IsBaseType = sg.GenSchema.HasDiscriminator
+	if sg.GenSchema.IsMap && sg.GenSchema.AdditionalProperties != nil {
+		// like arrays, a map of discriminated values is flagged as a base type
+		sg.GenSchema.IsBaseType = sg.GenSchema.AdditionalProperties.IsBaseType
+	}
 
 	if err := sg.buildProperties(); err != nil {
 		return err
 	}
 
+	if err := sg.flattenAllOf(); err != nil {
+		return err
+	}
+
 	if err := sg.buildXMLName(); err != nil {
 		return err
 	}
@@ -1523,22 +2376,33 @@ func (sg *schemaGenContext) makeGenSchema() error {
 		return err
 	}
 
+	if union := sg.GenSchema.Union(); len(union) > 0 && !sg.Named {
+		sg.GenSchema.KclType = unionType(union)
+	}
+	if sg.IsElement && sg.GenSchema.IsPrimitive && len(sg.GenSchema.Enum) > 0 {
+		sg.GenSchema.KclType = enumType(sg.GenSchema.Enum)
+	}
+
 	sg.GenSchema.Extensions = sg.Schema.Extensions
 	debugLog("finished gen schema for %q", sg.Name)
 	return nil
 }
 
-func RecoverMapValueOrder(oldValue interface{}) interface{} {
+// RecoverMapValueOrder recovers the order of the maps of a default or example value, whose values are wrapped
+// along with their x-order by the spec preprocessing. It fails on an x-order which is not the index of a key.
+func RecoverMapValueOrder(oldValue interface{}) (interface{}, error) {
 	value := reflect.ValueOf(oldValue)
 	switch value.Kind() {
 	case reflect.Slice:
 		var newSlice []interface{}
 		for i := 0; i < value.Len(); i++ {
-			itemValue := value.Index(i).Interface()
-			RecoverMapValueOrder(itemValue)
+			itemValue, err := RecoverMapValueOrder(value.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
 			newSlice = append(newSlice, itemValue)
 		}
-		return newSlice
+		return newSlice, nil
 	case reflect.Map:
 		keys := value.MapKeys()
 		var newValue yaml.MapSlice = make([]yaml.MapItem, len(keys))
@@ -1547,42 +2411,95 @@ func RecoverMapValueOrder(oldValue interface{}) interface{} {
 			k := key.Interface()
 			v := value.MapIndex(key).Interface()
 			mapV := reflect.ValueOf(v)
-			switch mapV.Kind() {
-			case reflect.Map:
+			index := i
+			if mapV.Kind() == reflect.Map {
 				hasXOrder := false
-				var order int64
 				var innerValue interface{}
 				mapIter := mapV.MapRange()
 				for mapIter.Next() {
 					kk := mapIter.Key().String()
 					if kk == xOrder {
+						order, ok := mapIter.Value().Interface().(float64)
+						if !ok || order < 0 || int(order) >= len(keys) || order != float64(int(order)) {
+							return nil, fmt.Errorf("unexpected %s %v of the value of %v in the ordered map %v",
+								xOrder, mapIter.Value().Interface(), k, oldValue)
+						}
 						hasXOrder = true
-						order = int64(mapIter.Value().Interface().(float64))
+						index = int(order)
 					}
 					if kk == "value" {
 						innerValue = mapIter.Value().Interface()
 					}
 				}
 				if hasXOrder {
-					newValue[order] = yaml.MapItem{
-						Key:   k,
-						Value: RecoverMapValueOrder(innerValue),
-					}
-				} else {
-					newValue[i] = yaml.MapItem{
-						Key:   k,
-						Value: RecoverMapValueOrder(v),
-					}
-				}
-			default:
-				newValue[i] = yaml.MapItem{
-					Key:   k,
-					Value: RecoverMapValueOrder(v),
+					v = innerValue
 				}
 			}
+			recovered, err := RecoverMapValueOrder(v)
+			if err != nil {
+				return nil, err
+			}
+			newValue[index] = yaml.MapItem{Key: k, Value: recovered}
 		}
-		return newValue
+		return newValue, nil
 	default:
-		return oldValue
+		return oldValue, nil
+	}
+}
+
+// namedExamples returns the named examples listed by the x-examples extension of a schema, into which the
+// OpenAPI 3.0 "examples" field is converted. It maps the name of each example to an example object holding its
+// value. The examples are sorted by name, unless the order of the spec is kept. The examples without a value,
+// such as the external ones, are skipped.
+func namedExamples(examples interface{}, keepOrder bool) ([]GenExample, error) {
+	var items yaml.MapSlice
+	if keepOrder {
+		recovered, err := RecoverMapValueOrder(examples)
+		if err != nil {
+			return nil, err
+		}
+		items, _ = recovered.(yaml.MapSlice)
+	} else if m, ok := examples.(map[string]interface{}); ok {
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			items = append(items, yaml.MapItem{Key: name, Value: m[name]})
+		}
+	}
+	var named []GenExample
+	for _, item := range items {
+		name, _ := item.Key.(string)
+		var value interface{}
+		switch example := item.Value.(type) {
+		case yaml.MapSlice:
+			value, _ = lookForField(example, "value")
+		case map[string]interface{}:
+			value = example["value"]
+		}
+		if name == "" || value == nil {
+			continue
+		}
+		named = append(named, GenExample{Name: DefaultLanguageFunc().MangleVarName(name), Value: value})
+	}
+	return named, nil
+}
+
+// exampleInstance renders the example of a definition as an instance of its schema, e.g.
+// pet_example = Pet {"name": "doggie"}. It returns an empty string when the example is not an object,
+// or when the definition is not rendered as a schema, such as a union.
+func exampleInstance(sch *GenSchema) string {
+	switch sch.Example.(type) {
+	case map[string]interface{}, yaml.MapSlice:
+	default:
+		return ""
+	}
+	if sch.Union() != nil || (sch.Enum != nil && sch.IsPrimitive) {
+		return ""
 	}
+	lang := DefaultLanguageFunc()
+	name := sch.KclType[strings.LastIndex(sch.KclType, ".")+1:]
+	return fmt.Sprintf("%s_example = %s %s", lang.MangleFileName(name), name, lang.ToKclValue(sch.Example))
 }
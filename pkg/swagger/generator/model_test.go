@@ -0,0 +1,1442 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+	crdGen "kcl-lang.io/kcl-openapi/pkg/kube_resource/generator"
+)
+
+// generateFromSpec generates the models of an inline spec in a temporary directory
+// and returns the content of each generated file, keyed by its path relative to the
+// models package.
+func generateFromSpec(t *testing.T, content string, configure func(opts *GenOpts)) map[string]string {
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "spec.yaml")
+	if err := os.WriteFile(specPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := new(GenOpts)
+	opts.Spec = specPath
+	opts.Target = filepath.Join(dir, "output")
+	opts.KeepOrder = true
+	opts.ModelPackage = "models"
+	if configure != nil {
+		configure(opts)
+	}
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Generate(opts); err != nil {
+		t.Fatal(err)
+	}
+	return readGenerated(t, filepath.Join(opts.Target, opts.ModelPackage))
+}
+
+func readGenerated(t *testing.T, root string) map[string]string {
+	files := map[string]string{}
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		// nothing is generated
+		return files
+	}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// captureLog returns the log output written while running fn.
+func captureLog(t *testing.T, fn func()) string {
+	var buf bytes.Buffer
+	writer := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(writer)
+	fn()
+	return buf.String()
+}
+
+func assertContains(t *testing.T, content string, expects ...string) {
+	t.Helper()
+	for _, expect := range expects {
+		if !strings.Contains(content, expect) {
+			t.Fatalf("expect generated content to contain:\n%s\ngot:\n%s", expect, content)
+		}
+	}
+}
+
+func assertNotContains(t *testing.T, content string, unexpects ...string) {
+	t.Helper()
+	for _, unexpect := range unexpects {
+		if strings.Contains(content, unexpect) {
+			t.Fatalf("expect generated content not to contain:\n%s\ngot:\n%s", unexpect, content)
+		}
+	}
+}
+
+// generateFromCrd converts an inline CRD to a spec and generates its models like generateFromSpec.
+func generateFromCrd(t *testing.T, crd string, crdOpts *crdGen.GenOpts, configure func(opts *GenOpts)) map[string]string {
+	dir := t.TempDir()
+	crdPath := filepath.Join(dir, "crd.yaml")
+	if err := os.WriteFile(crdPath, []byte(crd), 0644); err != nil {
+		t.Fatal(err)
+	}
+	crdOpts.Spec = crdPath
+	specPath, err := crdGen.GetSpec(crdOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(filepath.Dir(specPath))
+	})
+	opts := new(GenOpts)
+	opts.Spec = specPath
+	opts.Target = filepath.Join(dir, "output")
+	opts.KeepOrder = true
+	opts.ModelPackage = "models"
+	if configure != nil {
+		configure(opts)
+	}
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Generate(opts); err != nil {
+		t.Fatal(err)
+	}
+	return readGenerated(t, filepath.Join(opts.Target, opts.ModelPackage))
+}
+
+func TestGenSummary(t *testing.T) {
+	var genOpts *GenOpts
+	summaryFile := filepath.Join(t.TempDir(), "summary.txt")
+	generateFromSpec(t, `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Owner:
+    type: object
+    properties:
+      name:
+        type: string
+        minLength: 1
+        maxLength: 10
+  Pet:
+    type: object
+    properties:
+      owner:
+        $ref: "#/definitions/Owner"
+      spec:
+        type: object
+        properties:
+          age:
+            type: integer
+            minimum: 1
+`, func(opts *GenOpts) {
+		opts.SummaryFile = summaryFile
+		genOpts = opts
+	})
+	summary := genOpts.summary
+	if summary.Definitions != 2 || summary.Files != 2 || summary.Checks != 3 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+	content, err := os.ReadFile(summaryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(content),
+		"definitions generated:  2\n",
+		"files written:          2\n",
+		"checks emitted:         3\n",
+		"warnings raised:        0\n",
+	)
+}
+
+func TestConcurrentGenerationWarnings(t *testing.T) {
+	warned := `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Pet:
+    type: object
+    properties:
+      apiVersion:
+        type: string
+        const: v1
+        default: v2
+      owner:
+        type: object
+        properties:
+          apiVersion:
+            type: string
+            const: v1
+            default: v2
+`
+	quiet := `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Owner:
+    type: object
+    properties:
+      name:
+        type: string
+`
+	optsList := make([]*GenOpts, 8)
+	var wg sync.WaitGroup
+	for i := range optsList {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content := quiet
+			if i%2 == 0 {
+				content = warned
+			}
+			dir := t.TempDir()
+			specPath := filepath.Join(dir, "spec.yaml")
+			if err := os.WriteFile(specPath, []byte(content), 0644); err != nil {
+				t.Error(err)
+				return
+			}
+			opts := &GenOpts{Spec: specPath, Target: filepath.Join(dir, "output"), ModelPackage: "models", Summary: true}
+			if err := opts.EnsureDefaults(); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := Generate(opts); err != nil {
+				t.Error(err)
+				return
+			}
+			optsList[i] = opts
+		}()
+	}
+	wg.Wait()
+	for i, opts := range optsList {
+		if opts == nil {
+			continue
+		}
+		expected := 0
+		if i%2 == 0 {
+			expected = 1
+		}
+		if len(opts.Warnings) != expected || opts.summary.Warnings != expected {
+			t.Errorf("generation %d: expected %d warning, got %v and a summary of %d warnings", i, expected, opts.Warnings, opts.summary.Warnings)
+		}
+	}
+}
+
+func TestCountChecks(t *testing.T) {
+	content := `schema Pet:
+    name?: str
+
+    check:
+        len(name) >= 1 if name
+        len(name) <= 10 if name
+
+
+schema PetSpec:
+    check:
+        age >= 1
+    age: int
+`
+	if got := countChecks([]byte(content)); got != 3 {
+		t.Fatalf("unexpected check count, expect: 3, got: %d", got)
+	}
+}
+
+// TestMapOfDiscriminatedType checks the base type flags of the maps, their models are checked by the
+// map_of_discriminated_type case of the integration tests
+func TestMapOfDiscriminatedType(t *testing.T) {
+	content := `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Animal:
+    type: object
+    discriminator: kind
+    required: [kind]
+    properties:
+      kind:
+        type: string
+      name:
+        type: string
+  Dog:
+    allOf:
+      - $ref: "#/definitions/Animal"
+      - type: object
+        properties:
+          bark:
+            type: boolean
+  Zoo:
+    type: object
+    properties:
+      animals:
+        type: object
+        additionalProperties:
+          $ref: "#/definitions/Animal"
+      dogs:
+        type: object
+        additionalProperties:
+          $ref: "#/definitions/Dog"
+`
+	specDoc := loadTestSpec(t, content)
+	opts := new(GenOpts)
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	def, err := makeGenDefinition("Zoo", "models", specDoc.Spec().Definitions["Zoo"], specDoc, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prop := range def.Properties {
+		if expect := prop.Name == "animals"; prop.IsBaseType != expect || prop.AdditionalProperties.IsBaseType != expect {
+			t.Fatalf("unexpected base type flags of the %s map, expect: %v, got: %v and %+v", prop.Name, expect, prop.IsBaseType, prop.AdditionalProperties)
+		}
+	}
+}
+
+func TestGenIndexModuleConflict(t *testing.T) {
+	// the index can't overwrite the module of a model
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "spec.yaml")
+	if err := os.WriteFile(specPath, []byte(`
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Index:
+    type: object
+    properties:
+      tab:
+        $ref: "#/definitions/CronTab"
+  CronTab:
+    type: object
+    x-kcl-type:
+      type: CronTab
+      import:
+        package: v1.cron_tab
+        alias: cron_tab
+    properties:
+      schedule:
+        type: string
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := &GenOpts{Spec: specPath, Target: dir, ModelPackage: "models", GenIndex: true}
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Generate(opts); err == nil || !strings.Contains(err.Error(), "conflicts with the module of the model Index") {
+		t.Fatalf("expected a conflict with the model Index, got %v", err)
+	}
+}
+
+func TestNoDefinitions(t *testing.T) {
+	output := captureLog(t, func() {
+		generateFromSpec(t, `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths:
+  /pets:
+    get:
+      responses:
+        200:
+          description: the pets
+          schema:
+            type: array
+            items:
+              type: object
+              properties:
+                name:
+                  type: string
+`, nil)
+	})
+	assertContains(t, output, "[WARN] the spec ", " has no definitions but only paths, no model is generated")
+
+	output = captureLog(t, func() {
+		generateFromSpec(t, `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+`, nil)
+	})
+	assertContains(t, output, " has no definitions, no model is generated")
+}
+
+func TestCyclicAllOf(t *testing.T) {
+	specPath := filepath.Join(t.TempDir(), "spec.yaml")
+	if err := os.WriteFile(specPath, []byte(`
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Animal:
+    discriminator: kind
+    required:
+      - kind
+    allOf:
+      - $ref: "#/definitions/Pet"
+      - type: object
+        properties:
+          kind:
+            type: string
+  Pet:
+    allOf:
+      - type: object
+        properties:
+          name:
+            type: string
+      - allOf:
+          - $ref: "#/definitions/Dog"
+  Dog:
+    allOf:
+      - $ref: "#/definitions/Animal"
+  Owner:
+    type: object
+    properties:
+      pet:
+        $ref: "#/definitions/Pet"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := new(GenOpts)
+	opts.Spec = specPath
+	opts.Target = filepath.Join(t.TempDir(), "output")
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	err := Generate(opts)
+	if err == nil || err.Error() != "cyclic allOf inheritance: Animal -> Pet -> Dog -> Animal" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRefPackages(t *testing.T) {
+	dir := t.TempDir()
+	common := filepath.Join(dir, "common.yaml")
+	if err := os.WriteFile(common, []byte(`
+swagger: "2.0"
+info:
+  title: common
+  version: v0.0.1
+paths: {}
+definitions:
+  Owner:
+    type: object
+    properties:
+      name:
+        type: string
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(dir, "main.yaml")
+	if err := os.WriteFile(main, []byte(`
+swagger: "2.0"
+info:
+  title: main
+  version: v0.0.1
+paths: {}
+definitions:
+  Pet:
+    type: object
+    required: [owner]
+    properties:
+      owner:
+        $ref: "common.yaml#/definitions/Owner"
+      friends:
+        type: array
+        items:
+          $ref: "./common.yaml#/definitions/Owner"
+      owners:
+        type: object
+        additionalProperties:
+          $ref: "common.yaml#/definitions/Owner"
+      ownerLists:
+        type: object
+        additionalProperties:
+          type: array
+          items:
+            $ref: "common.yaml#/definitions/Owner"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "output")
+	commonFiles := generateFromSpec(t, "", func(opts *GenOpts) {
+		opts.Spec = common
+		opts.Target = target
+		opts.ModelPackage = "common"
+	})
+	assertContains(t, commonFiles["owner.k"], "schema Owner:\n")
+	files := generateFromSpec(t, "", func(opts *GenOpts) {
+		opts.Spec = main
+		opts.Target = target
+		opts.RefPackages = map[string]string{common: "common"}
+	})
+	assertContains(t, files["pet.k"],
+		"import common\n",
+		"    owner: common.Owner\n",
+		"    friends?: [common.Owner]\n",
+		"    owners?: {str:common.Owner}\n",
+		"    ownerLists?: {str:[common.Owner]}\n",
+	)
+	// the referenced definition is imported instead of being bundled into the models
+	if _, ok := files["owner.k"]; ok {
+		t.Fatalf("unexpected file owner.k:\n%s", files["owner.k"])
+	}
+}
+
+func TestStrictEnumTypes(t *testing.T) {
+	// the enum values are only warned about by default, see the strict_enum_types case of the integration tests
+	opts := new(GenOpts)
+	opts.Spec = filepath.Join("testdata", "integration", "strict_enum_types", "strict_enum_types.golden.yaml")
+	opts.Target = filepath.Join(t.TempDir(), "output")
+	opts.StrictEnumTypes = true
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	err := Generate(opts)
+	if err == nil || !strings.Contains(err.Error(), "the enum values [\"2\"] of size do not match its type integer") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestExistingTargetFiles(t *testing.T) {
+	content := `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Pet:
+    type: object
+    properties:
+      name:
+        type: string
+`
+	prepareTarget := func(t *testing.T) string {
+		target := t.TempDir()
+		models := filepath.Join(target, "models")
+		if err := os.MkdirAll(models, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, data := range map[string]string{"pet.k": "schema Pet:\n    old: str\n", "stale.k": "schema Stale:\n    name: str\n", "README.md": "foreign"} {
+			if err := os.WriteFile(filepath.Join(models, name), []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return target
+	}
+
+	// the existing files are overwritten by default, and the stale ones are kept
+	target := prepareTarget(t)
+	files := generateFromSpec(t, content, func(opts *GenOpts) {
+		opts.Target = target
+	})
+	assertContains(t, files["pet.k"], "    name?: str\n")
+	assertNotContains(t, files["pet.k"], "old: str")
+	if _, ok := files["stale.k"]; !ok {
+		t.Fatal("expect the stale file to be kept")
+	}
+
+	// the stale files are removed when cleaning the models package
+	target = prepareTarget(t)
+	files = generateFromSpec(t, content, func(opts *GenOpts) {
+		opts.Target = target
+		opts.Clean = true
+	})
+	assertContains(t, files["pet.k"], "    name?: str\n")
+	if _, ok := files["stale.k"]; ok {
+		t.Fatal("expect the stale file to be removed")
+	}
+	if files["README.md"] != "foreign" {
+		t.Fatal("expect the foreign file to be kept")
+	}
+
+	// the target directory is not cleaned when the models package resolves to it
+	target = prepareTarget(t)
+	specPath := filepath.Join(t.TempDir(), "spec.yaml")
+	if err := os.WriteFile(specPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := new(GenOpts)
+	opts.Spec = specPath
+	opts.Target = filepath.Join(target, "models")
+	opts.Clean = true
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	err := Generate(opts)
+	if err == nil || !strings.Contains(err.Error(), "is the target directory, which is not cleaned") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files := readGenerated(t, opts.Target); files["stale.k"] == "" || files["README.md"] != "foreign" {
+		t.Fatalf("expect the target directory to be left untouched, got the files %v", files)
+	}
+
+	// an existing file fails the generation when overwriting is disabled
+	target = prepareTarget(t)
+	opts = new(GenOpts)
+	opts.Spec = specPath
+	opts.Target = target
+	opts.ModelPackage = "models"
+	opts.NoOverwrite = true
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	err = Generate(opts)
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(target, "models", "pet.k")+" already exists") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(target, "models", "pet.k"))
+	if err != nil || !strings.Contains(string(data), "old: str") {
+		t.Fatalf("expect the existing file to be kept, got %q: %v", data, err)
+	}
+
+	opts.Clean = true
+	if err := Generate(opts); err == nil || err.Error() != "the clean and no-overwrite options are mutually exclusive" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTranslateCEL(t *testing.T) {
+	for _, tc := range []struct {
+		rule    string
+		subject string
+		fields  map[string]bool
+		expr    string
+	}{
+		{rule: "self.a <= self.b", expr: "a <= b"},
+		{rule: "self.a > 0 ? self.b == 'x' : self.c", expr: "b == \"x\" if a > 0 else c"},
+		{rule: "!(self.a || self.b) && !self.c", expr: "not (a or b) and not c"},
+		{rule: "!self.a == self.b", expr: "(not a) == b"},
+		{rule: "(self.a + 1) * 2 >= 10u", expr: "(a + 1) * 2 >= 10"},
+		{rule: "self.a - (self.b - self.c) == -self.d", expr: "a - (b - c) == -d"},
+		{rule: "has(self.spec.x) && self.spec.items.size() > 0", expr: "spec.x not in [None, Undefined] and len(spec.items) > 0"},
+		{rule: "self.contains(\"x\") || self.endsWith('\\'')", subject: "name", expr: "\"x\" in name or name.endswith(\"'\")"},
+		{rule: "size(self) < 5 && self[0] != null", subject: "items", expr: "len(items) < 5 and items[0] != None"},
+		{rule: "self.schema == true", expr: "$schema == True"},
+		{rule: "self.all(x, x > 0)", subject: "items"},
+		{rule: "self == oldSelf", subject: "name"},
+		{rule: "self.size() > 0"},
+		{rule: "self.matches('^a')", subject: "name"},
+		{rule: "self.a >"},
+		{rule: "has(self.a) && self.b > 0", fields: map[string]bool{"a": true, "b": true}, expr: "a not in [None, Undefined] and b > 0"},
+		{rule: "has(self.c)", fields: map[string]bool{"a": true}},
+	} {
+		expr, err := translateCEL(tc.rule, tc.subject, tc.fields)
+		if tc.expr == "" {
+			if err == nil {
+				t.Errorf("expect the rule %q not to be translated, got %q", tc.rule, expr)
+			}
+			continue
+		}
+		if err != nil || expr != tc.expr {
+			t.Errorf("expect the rule %q to be translated into %q, got %q: %v", tc.rule, tc.expr, expr, err)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	target := t.TempDir()
+	models := filepath.Join(target, "models")
+	if err := os.MkdirAll(models, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(models, "stale.k"), []byte("schema Stale:\n    name: str\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	original := os.Stdout
+	os.Stdout = stdout
+	defer func() { os.Stdout = original }()
+
+	files := generateFromSpec(t, `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Pet:
+    type: object
+    properties:
+      name:
+        type: string
+  Owner:
+    type: object
+    properties:
+      pets:
+        type: array
+        items:
+          $ref: "#/definitions/Pet"
+`, func(opts *GenOpts) {
+		opts.Target = target
+		opts.Clean = true
+		opts.DryRun = true
+	})
+	os.Stdout = original
+
+	if len(files) != 1 || files["stale.k"] == "" {
+		t.Fatalf("expect the target to be left untouched, got the files %v", files)
+	}
+	if _, err := os.Stat(filepath.Join(target, "models", "pet.k")); !os.IsNotExist(err) {
+		t.Fatalf("expect pet.k not to be written: %v", err)
+	}
+	output, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(output),
+		"# "+filepath.Join(models, "pet.k")+"\n",
+		"schema Pet:\n",
+		"# "+filepath.Join(models, "owner.k")+"\n",
+		"    pets?: [Pet]\n",
+	)
+}
+
+func TestUnknownModelNames(t *testing.T) {
+	opts := &GenOpts{
+		Spec:         filepath.Join("testdata", "options", "model_names", "model_names.golden.yaml"),
+		Target:       t.TempDir(),
+		ModelPackage: "models",
+		ModelNames:   []string{"Deployment", "Pod"},
+	}
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Generate(opts); err == nil || err.Error() != "unknown models: Pod" {
+		t.Fatalf("expected the model Pod to be unknown, got %v", err)
+	}
+}
+
+func TestGenMod(t *testing.T) {
+	spec := `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Pet:
+    type: object
+    properties:
+      name:
+        type: string
+`
+	var target string
+	readMod := func() string {
+		data, err := os.ReadFile(filepath.Join(target, "kcl.mod"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	generateFromSpec(t, spec, func(opts *GenOpts) {
+		opts.GenMod = true
+		target = opts.Target
+	})
+	if mod := readMod(); mod != "[package]\nname = \"output\"\nversion = \"0.0.1\"\n" {
+		t.Fatalf("unexpected kcl.mod:\n%s", mod)
+	}
+
+	// an existing kcl.mod is kept, unless it is forced to be overwritten
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "spec.yaml")
+	if err := os.WriteFile(specPath, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target = dir
+	for _, force := range []bool{false, true} {
+		if err := os.WriteFile(filepath.Join(dir, "kcl.mod"), []byte("[package]\nname = \"mine\"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		opts := &GenOpts{Spec: specPath, Target: dir, ModelPackage: "models", GenMod: true, ModName: "pets", Force: force}
+		if err := opts.EnsureDefaults(); err != nil {
+			t.Fatal(err)
+		}
+		if err := Generate(opts); err != nil {
+			t.Fatal(err)
+		}
+		if mod := readMod(); strings.Contains(mod, `name = "pets"`) != force {
+			t.Errorf("unexpected kcl.mod with force %t:\n%s", force, mod)
+		}
+	}
+
+	// the CRDs import the ObjectMeta of the k8s package
+	generateFromCrd(t, `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: apps.example.com
+spec:
+  group: example.com
+  names:
+    kind: App
+    plural: apps
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+`, &crdGen.GenOpts{}, func(opts *GenOpts) {
+		opts.GenMod = true
+		opts.ModName = "apps"
+		target = opts.Target
+	})
+	assertContains(t, readMod(), `name = "apps"
+version = "0.0.1"
+
+[dependencies]
+k8s = "1.28"
+`)
+}
+
+func TestConcurrentRendering(t *testing.T) {
+	var spec strings.Builder
+	spec.WriteString("swagger: \"2.0\"\ninfo:\n  title: kcl\n  version: v0.0.1\npaths: {}\ndefinitions:\n")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&spec, "  Model%d:\n    type: object\n    properties:\n      name:\n        type: string\n        pattern: \"^[a-z]+$\"\n      next:\n        $ref: \"#/definitions/Model%d\"\n", i, (i+1)%20)
+	}
+	sequential := generateFromSpec(t, spec.String(), func(opts *GenOpts) {
+		opts.Workers = 1
+	})
+	concurrent := generateFromSpec(t, spec.String(), func(opts *GenOpts) {
+		opts.Workers = 4
+	})
+	if len(concurrent) != 20 || !reflect.DeepEqual(sequential, concurrent) {
+		t.Fatalf("the models rendered concurrently differ from the ones rendered sequentially")
+	}
+}
+
+func TestGenerateModels(t *testing.T) {
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "spec.yaml")
+	if err := os.WriteFile(specPath, []byte(`swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Pet:
+    type: object
+    properties:
+      kind:
+        type: string
+        enum: [cat, dog]
+        default: bird
+      category:
+        $ref: "#/definitions/Category"
+  Category:
+    type: object
+    properties:
+      id:
+        type: integer
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := GenerateModels(context.Background(), Config{Spec: specPath, SkipValidation: true, Options: &GenOpts{GenIndex: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for path := range result.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if expected := []string{"models/category.k", "models/index.k", "models/pet.k"}; !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected the files %v, got %v", expected, paths)
+	}
+	assertContains(t, string(result.Files["models/pet.k"]), "schema Pet:")
+	assertContains(t, string(result.Files["models/pet.k"]), "category?: Category")
+	if _, err := os.Stat("models"); !os.IsNotExist(err) {
+		t.Fatalf("no file is expected to be written, got %v", err)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0].Message, `the default value "bird" of kind is not one of its enum values`) {
+		t.Fatalf("expected the warning of the default value, got %q", result.Warnings)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := GenerateModels(ctx, Config{Spec: specPath, SkipValidation: true}); err != context.Canceled {
+		t.Fatalf("expected the generation to be canceled, got %v", err)
+	}
+}
+
+func TestGenerateModelsFromCRD(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the temporary directory is set by TMPDIR")
+	}
+	// the temporary files of the generation are written to, and removed from, a temporary directory of the test
+	tmp := t.TempDir()
+	crdPath := filepath.Join(t.TempDir(), "crd.yaml")
+	t.Setenv("TMPDIR", tmp)
+	if err := os.WriteFile(crdPath, []byte(`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: apps.example.com
+spec:
+  group: example.com
+  names:
+    kind: App
+    plural: apps
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          image:
+            type: string
+  - name: v2
+    served: true
+    storage: false
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          image:
+            type: string
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := GenerateModels(context.Background(), Config{
+		Spec:               crdPath,
+		CRD:                true,
+		CRDMetadataType:    "base.meta.ObjectMeta",
+		CRDVersion:         "v2",
+		CRDVersionPackages: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for path := range result.Files {
+		paths = append(paths, path)
+	}
+	if len(paths) != 1 || !strings.HasPrefix(paths[0], "models/v2/") {
+		t.Fatalf("expected the model of the v2 version in the v2 package, got %v", paths)
+	}
+	assertContains(t, string(result.Files[paths[0]]), "import base.meta", "metadata?: meta.ObjectMeta")
+	if entries, err := os.ReadDir(tmp); err != nil || len(entries) != 0 {
+		t.Fatalf("expected the temporary files to be removed, got %v: %v", entries, err)
+	}
+
+	// a malformed spec fails the generation
+	specPath := filepath.Join(filepath.Dir(crdPath), "spec.yaml")
+	if err := os.WriteFile(specPath, []byte("swagger: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GenerateModels(context.Background(), Config{Spec: specPath, SkipValidation: true}); err == nil {
+		t.Fatal("expected the malformed spec to fail the generation")
+	}
+}
+
+func TestExternalRefs(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"spec.yaml": `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Pet:
+    type: object
+    properties:
+      address:
+        $ref: "./common.yaml#/Address"
+      owner:
+        $ref: "owners/owner.yaml#/definitions/Owner"
+`,
+		"common.yaml": `
+Address:
+  type: object
+  properties:
+    street:
+      type: string
+    country:
+      $ref: "#/Country"
+Country:
+  type: string
+  enum: [fr, us]
+`,
+		"owners/owner.yaml": `
+definitions:
+  Owner:
+    type: object
+    properties:
+      address:
+        $ref: "../common.yaml#/Address"
+`,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := new(GenOpts)
+	opts.Spec = filepath.Join(dir, "spec.yaml")
+	opts.Target = filepath.Join(dir, "output")
+	opts.ValidateSpec = true
+	opts.KeepOrder = true
+	opts.ModelPackage = "models"
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Generate(opts); err != nil {
+		t.Fatal(err)
+	}
+	files := readGenerated(t, filepath.Join(opts.Target, "models"))
+	if len(files) != 4 {
+		t.Fatalf("expected the models of the spec and of the files it refers to, got %d files", len(files))
+	}
+	assertContains(t, files["pet.k"], "    address?: Address\n", "    owner?: Owner\n")
+	assertContains(t, files["owner.k"], "schema Owner:", "    address?: Address\n")
+	assertContains(t, files["address.k"], "schema Address:", "    country?: Country\n")
+	assertContains(t, files["country.k"], "type Country = \"fr\" | \"us\"")
+}
+
+func TestInvalidFileNaming(t *testing.T) {
+	specPath := filepath.Join("testdata", "options", "file_naming_camelize", "file_naming_camelize.golden.yaml")
+	for naming, expected := range map[string]string{
+		"{{ camelize .Name }.k":        `invalid file naming "{{ camelize .Name }.k": template: file-naming:1: unexpected "}" in operand`,
+		"{{ .Model }}.k":               `invalid file naming "{{ .Model }}.k": template: file-naming:1:3: executing "file-naming" at <.Model>: can't evaluate field Model`,
+		"{{ .Package }}/{{ .Name }}.k": `invalid file naming "{{ .Package }}/{{ .Name }}.k": the file name "models/Pet.k" of the model Pet is not the name of a file`,
+	} {
+		opts := &GenOpts{Spec: specPath, Target: t.TempDir(), ModelPackage: "models", FileNaming: naming}
+		if err := opts.EnsureDefaults(); err != nil {
+			t.Fatal(err)
+		}
+		if err := Generate(opts); err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Fatalf("unexpected error for the file naming %s: %v", naming, err)
+		}
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	writeConfig := func(content string) {
+		if err := os.WriteFile(config, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "templates", "doc.gotmpl"), []byte(`# {{ .Name }}
+{{ range .Properties }}
+- {{ .Name }}: {{ .KclType }}
+{{- end }}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(`
+models:
+- name: definition
+  source: asset:model
+  target: "{{ joinFilePath .Target (toFilePath .Package) }}"
+  file_name: "{{ (snakize (pascalize (.Name))) }}.k"
+- name: doc
+  source: templates/doc.gotmpl
+  target: "{{ joinFilePath .Target (toFilePath .Package) }}"
+  file_name: "{{ .Name }}.md"
+  skip_format: true
+`)
+	sections, err := ReadConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := generateFromSpec(t, `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  PetOwner:
+    type: object
+    properties:
+      name:
+        type: string
+`, func(opts *GenOpts) {
+		opts.Sections = sections
+	})
+	assertContains(t, files["pet_owner.k"], "schema PetOwner:")
+	if files["PetOwner.md"] != "# PetOwner\n\n- name: str\n" {
+		t.Fatalf("unexpected doc:\n%s", files["PetOwner.md"])
+	}
+
+	writeConfig(`
+models:
+- name: doc
+  source: templates/missing.gotmpl
+`)
+	sections, err = ReadConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &GenOpts{Sections: sections}
+	if err := opts.CheckOpts(); err == nil || !strings.HasPrefix(err.Error(), "the template "+filepath.Join(dir, "templates", "missing.gotmpl")+" of the section doc can't be loaded") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	writeConfig(`
+models:
+- name: doc
+  file: doc.gotmpl
+`)
+	if _, err := ReadConfig(config); err == nil || !strings.Contains(err.Error(), "invalid keys: file") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTemplateDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "docstring.gotmpl"), []byte(`{{ define "docstring" }}    The {{ .Name }} schema.{{ end }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	content := `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Pet:
+    type: object
+    description: A pet.
+    properties:
+      name:
+        type: string
+`
+	output := captureLog(t, func() {
+		files := generateFromSpec(t, content, func(opts *GenOpts) {
+			opts.TemplateDir = dir
+		})
+		assertContains(t, files["pet.k"], "schema Pet:\n    \"\"\"\n    The Pet schema.\n    \"\"\"")
+		assertNotContains(t, files["pet.k"], "A pet.", "Attributes")
+	})
+	assertContains(t, output, "the protected template docstring is overridden by "+filepath.Join(dir, "docstring.gotmpl"))
+
+	// the overrides are kept to the generation of the template directory
+	files := generateFromSpec(t, content, nil)
+	assertContains(t, files["pet.k"], "    A pet.\n", "Attributes")
+
+	// nor do they leak into the generations running alongside
+	generated := make([]string, 8)
+	var wg sync.WaitGroup
+	for i := range generated {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts := &GenOpts{Spec: filepath.Join(t.TempDir(), "spec.yaml"), Target: t.TempDir(), ModelPackage: "models"}
+			if i%2 == 0 {
+				opts.TemplateDir = dir
+			}
+			if err := os.WriteFile(opts.Spec, []byte(content), 0644); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := opts.EnsureDefaults(); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := Generate(opts); err != nil {
+				t.Error(err)
+				return
+			}
+			data, err := os.ReadFile(filepath.Join(opts.Target, "models", "pet.k"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			generated[i] = string(data)
+		}()
+	}
+	wg.Wait()
+	for i, pet := range generated {
+		if i%2 == 0 {
+			assertContains(t, pet, "    The Pet schema.\n")
+		} else {
+			assertContains(t, pet, "    A pet.\n", "Attributes")
+		}
+	}
+
+	opts := &GenOpts{TemplateDir: filepath.Join(dir, "missing")}
+	if err := opts.CheckOpts(); err == nil || !strings.HasPrefix(err.Error(), "could not locate the template directory") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	content := `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Pet:
+    type: object
+    properties:
+      id:
+        type: [integer, string]
+`
+	files := generateFromSpec(t, content, nil)
+	assertContains(t, files["pet.k"], "    id?: int\n")
+
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "spec.yaml")
+	if err := os.WriteFile(specPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := new(GenOpts)
+	opts.Spec = specPath
+	opts.Target = filepath.Join(dir, "output")
+	opts.Strict = true
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	err := Generate(opts)
+	if err == nil || !strings.HasPrefix(err.Error(), "the generation raised a warning in strict mode: unsupported: Pet: JSON-Schema type definition as array with several types is not supported") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files := readGenerated(t, filepath.Join(opts.Target, "models")); len(files) != 0 {
+		t.Fatalf("no model is expected to be written, got %d files", len(files))
+	}
+}
+
+func TestComplexEnumError(t *testing.T) {
+	specPath := filepath.Join(t.TempDir(), "spec.yaml")
+	if err := os.WriteFile(specPath, []byte(`
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Pet:
+    type: object
+    properties:
+      owner:
+        type: object
+        enum:
+        - name: alice
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := new(GenOpts)
+	opts.Spec = specPath
+	opts.Target = filepath.Join(t.TempDir(), "output")
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	err := Generate(opts)
+	if err == nil || !strings.Contains(err.Error(), `enum values in model <Pet.owner> contains complex value type which is forbidden in KCL: {"name": "alice"}`) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRecoverMapValueOrder(t *testing.T) {
+	value, err := RecoverMapValueOrder([]interface{}{
+		map[string]interface{}{
+			"b": map[string]interface{}{"value": "x", xOrder: float64(0)},
+			"a": map[string]interface{}{"value": "y", xOrder: float64(1)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{yaml.MapSlice{{Key: "b", Value: "x"}, {Key: "a", Value: "y"}}}
+	if !reflect.DeepEqual(value, expected) {
+		t.Fatalf("expected %v, got %v", expected, value)
+	}
+
+	_, err = RecoverMapValueOrder(map[string]interface{}{
+		"b": map[string]interface{}{"value": "x", xOrder: float64(3)},
+	})
+	if err == nil || !strings.Contains(err.Error(), "unexpected x-order 3 of the value of b") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// BenchmarkRenderDefinitions renders the models of a spec of 500 definitions, planned once, with a single worker or
+// with a worker per CPU, e.g. go test -run ^$ -bench RenderDefinitions
+func BenchmarkRenderDefinitions(b *testing.B) {
+	var spec strings.Builder
+	spec.WriteString("swagger: \"2.0\"\ninfo:\n  title: kcl\n  version: v0.0.1\npaths: {}\ndefinitions:\n")
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&spec, `  Model%d:
+    type: object
+    required:
+    - name
+    properties:
+      name:
+        type: string
+        maxLength: 63
+        pattern: "^[a-z]+$"
+      replicas:
+        type: integer
+        minimum: 0
+      labels:
+        type: object
+        additionalProperties:
+          type: string
+      next:
+        $ref: "#/definitions/Model%d"
+`, i, (i+1)%500)
+	}
+	dir := b.TempDir()
+	specPath := filepath.Join(dir, "spec.yaml")
+	if err := os.WriteFile(specPath, []byte(spec.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	writer := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(writer)
+	opts := &GenOpts{Spec: specPath, Target: dir, ModelPackage: "models", KeepOrder: true}
+	if err := opts.EnsureDefaults(); err != nil {
+		b.Fatal(err)
+	}
+	gen, err := newGenerator(context.Background(), opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	app, err := gen.makeCodegen()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, bench := range []struct {
+		name    string
+		workers int
+	}{{"sequential", 1}, {"concurrent", runtime.NumCPU()}} {
+		b.Run(bench.name, func(b *testing.B) {
+			opts.Workers = bench.workers
+			for i := 0; i < b.N; i++ {
+				if err := gen.renderDefinitions(app.Models); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidAdditionalPropsName(t *testing.T) {
+	opts := &GenOpts{AdditionalPropsName: "a-b"}
+	if err := opts.CheckOpts(); err == nil || err.Error() != "invalid additional properties name a-b, expect letters, digits or underscores" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestConflictingImportAlias(t *testing.T) {
+	imp := map[string]importStmt{
+		"x.v1":        {ImportPath: "x.v1", AsName: "v1"},
+		"models.a.v1": {ImportPath: "a.v1", AsName: "aV1"},
+		"b.a.v1":      {ImportPath: "a.v1", AsName: "aV1_2"},
+	}
+	if asName := getImportAsName(imp, "a.v1", "baz"); asName != "aV1_3" {
+		t.Fatalf("expected the import to be named aV1_3, got %s", asName)
+	}
+
+	// the models rendered into a single file import different packages as v1
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "spec.yaml")
+	if err := os.WriteFile(specPath, []byte(`swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Cat:
+    type: object
+    properties:
+      foo:
+        x-kcl-type:
+          type: Foo
+          import:
+            package: x.v1.foo
+  Dog:
+    type: object
+    properties:
+      bar:
+        x-kcl-type:
+          type: Bar
+          import:
+            package: y.v1.bar
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := new(GenOpts)
+	opts.Spec = specPath
+	opts.Target = filepath.Join(dir, "output")
+	opts.ModelPackage = "models"
+	opts.SingleFile = true
+	if err := opts.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Generate(opts); err == nil || !strings.Contains(err.Error(), "the packages x.v1 and y.v1 are both imported as v1 in the models file models") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// modelsFileTemplate renders all the models of a package into a single file named after the package
+var modelsFileTemplate = TemplateOpts{
+	Name:     "models-file",
+	Source:   "asset:modelsfile",
+	Target:   "{{ joinFilePath .Target (toFilePath .Package) }}",
+	FileName: "{{ .Name }}.k",
+}
+
+// GenModelsFile contains the information needed to render all the models of a package into a single file
+type GenModelsFile struct {
+	GenCommon
+	Name                 string
+	Package              string
+	Pkg                  string
+	Module               string
+	Imports              []importStmt
+	HasPatternValidation bool
+	Models               GenDefinitions
+}
+
+// newGenModelsFile returns the file the models of the package of the model are rendered into
+func newGenModelsFile(mod *GenDefinition) *GenModelsFile {
+	pkg := mod.Package
+	if mod.Pkg != "" {
+		pkg = mod.Pkg
+	}
+	return &GenModelsFile{
+		GenCommon: mod.GenCommon,
+		Name:      pkg[strings.LastIndex(pkg, ".")+1:],
+		Package:   mod.Package,
+		Pkg:       mod.Pkg,
+	}
+}
+
+// renderModelsFiles renders the models into a single file per package, in the order of their names.
+// The imports of the models are merged, and an extra schema shared by several models is rendered once.
+func (a *generator) renderModelsFiles(models GenDefinitions) error {
+	files := make(map[string]*GenModelsFile)
+	var keys []string
+	for i := range models {
+		mod := models[i]
+		key := mod.Package + "." + mod.Pkg
+		file, ok := files[key]
+		if !ok {
+			file = newGenModelsFile(&mod)
+			files[key] = file
+			keys = append(keys, key)
+		}
+		file.Models = append(file.Models, mod)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		file := files[key]
+		if err := file.mergeModels(a.GenOpts.warnings); err != nil {
+			return err
+		}
+		if err := a.GenOpts.write(&modelsFileTemplate, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeModels sorts the models of the file, merges their imports and drops the extra schemas already rendered.
+// The models referring to the types of different packages imported as the same name can't be merged, as the
+// types are already named after the import.
+func (f *GenModelsFile) mergeModels(warnings *warningRecorder) error {
+	sort.Sort(f.Models)
+	builtInImps := map[string]importStmt{}
+	pkgImps := map[string]importStmt{}
+	// the import paths by the name they are imported as
+	paths := map[string]string{}
+	rendered := make(map[string]struct{})
+	for i := range f.Models {
+		rendered[f.Models[i].Name] = struct{}{}
+	}
+	for i := range f.Models {
+		mod := &f.Models[i]
+		for _, imp := range mod.Imports {
+			imps := pkgImps
+			if imp.IsBuiltIn {
+				imps = builtInImps
+			}
+			if existing, ok := imps[imp.ImportPath]; ok && existing.AsName != imp.AsName {
+				warnings.warn("", "the package %s is imported both as %s and %s in the models file %s, please resolve it properly",
+					imp.ImportPath, existing.AsName, imp.AsName, f.Name)
+				continue
+			}
+			if path, ok := paths[imp.AsName]; ok && path != imp.ImportPath {
+				return fmt.Errorf("the packages %s and %s are both imported as %s in the models file %s, "+
+					"generate a file per model instead of a single file, or set the alias of the x-kcl-type import",
+					path, imp.ImportPath, imp.AsName, f.Name)
+			}
+			paths[imp.AsName] = imp.ImportPath
+			imps[imp.ImportPath] = imp
+		}
+		f.HasPatternValidation = f.HasPatternValidation || mod.HasPatternValidation
+		var extraSchemas GenSchemaList
+		for _, extra := range mod.ExtraSchemas {
+			if _, ok := rendered[extra.Name]; ok {
+				continue
+			}
+			rendered[extra.Name] = struct{}{}
+			extraSchemas = append(extraSchemas, extra)
+		}
+		mod.ExtraSchemas = extraSchemas
+	}
+	f.Imports = append(sortImports(builtInImps), sortImports(pkgImps)...)
+	return nil
+}
+
+// modelLocation returns the directory and the name of the file the model is rendered into
+func (a *generator) modelLocation(mod *GenDefinition) (string, string, error) {
+	if a.GenOpts.SingleFile {
+		return a.GenOpts.location(&modelsFileTemplate, newGenModelsFile(mod))
+	}
+	return a.GenOpts.location(&a.GenOpts.Sections.Models[0], mod)
+}
@@ -0,0 +1,153 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-openapi/swag"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// OpenAPIVersion2 forces the spec to be read as a Swagger 2.0 document
+	OpenAPIVersion2 = "2.0"
+	// OpenAPIVersion3 forces the spec to be read as an OpenAPI 3.0 document
+	OpenAPIVersion3 = "3.0"
+
+	// oas3SchemaRefPrefix is the prefix of the local references to the schemas of an OpenAPI 3.0 document
+	oas3SchemaRefPrefix = "#/components/schemas/"
+	// swaggerDefinitionRefPrefix is the prefix of the local references to the definitions of a Swagger 2.0 document
+	swaggerDefinitionRefPrefix = "#/definitions/"
+)
+
+// WithSwagger2 converts an OpenAPI 3.0 spec down to a Swagger 2.0 spec, written to a temporary file.
+// The version of the spec is detected from its "openapi" field, unless it is forced by the version argument
+// to OpenAPIVersion2 or OpenAPIVersion3. A Swagger 2.0 spec is returned unchanged.
+//
+// Only the models are converted: the schemas under components/schemas are moved into the definitions,
+// while the paths and the other components are dropped, since the operations are not supported.
+func WithSwagger2(specPath, version string) (string, error) {
+	if version == OpenAPIVersion2 {
+		return specPath, nil
+	}
+	yamlDoc, err := swag.YAMLData(specPath)
+	if err != nil {
+		return "", err
+	}
+	doc, ok := yamlDoc.(yaml.MapSlice)
+	if !ok {
+		return specPath, nil
+	}
+	if version == "" {
+		detected, _ := lookForField(doc, "openapi")
+		if s, ok := detected.(string); !ok || !strings.HasPrefix(s, "3.") {
+			return specPath, nil
+		}
+	}
+	converted, err := toSwagger2(doc)
+	if err != nil {
+		return "", fmt.Errorf("could not convert the OpenAPI 3.0 spec %s: %v", specPath, err)
+	}
+	out, err := yaml.Marshal(converted)
+	if err != nil {
+		return "", err
+	}
+	tmpFile, err := os.CreateTemp("", filepath.Base(specPath))
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(tmpFile.Name(), out, 0); err != nil {
+		return "", err
+	}
+	return tmpFile.Name(), nil
+}
+
+// toSwagger2 converts the root of an OpenAPI 3.0 document into the root of a Swagger 2.0 document
+func toSwagger2(doc yaml.MapSlice) (yaml.MapSlice, error) {
+	converted := yaml.MapSlice{{Key: "swagger", Value: "2.0"}}
+	info, ok := lookForField(doc, "info")
+	if !ok {
+		return nil, fmt.Errorf("the info field is missing")
+	}
+	converted = append(converted, yaml.MapItem{Key: "info", Value: info}, yaml.MapItem{Key: "paths", Value: yaml.MapSlice{}})
+	if components, ok := lookForMapSlice(doc, "components"); ok {
+		if schemas, ok := lookForMapSlice(components, "schemas"); ok {
+			converted = append(converted, yaml.MapItem{Key: "definitions", Value: toSwagger2Schemas(schemas)})
+		}
+	}
+	for _, field := range doc {
+		if key, ok := field.Key.(string); ok && strings.HasPrefix(key, "x-") {
+			converted = append(converted, field)
+		}
+	}
+	return converted, nil
+}
+
+// toSwagger2Schemas converts the schemas of a map, such as the definitions or the properties of a schema
+func toSwagger2Schemas(schemas yaml.MapSlice) yaml.MapSlice {
+	converted := make(yaml.MapSlice, 0, len(schemas))
+	for _, field := range schemas {
+		converted = append(converted, yaml.MapItem{Key: field.Key, Value: toSwagger2Schema(field.Value)})
+	}
+	return converted
+}
+
+// toSwagger2Schema rewrites the OpenAPI 3.0 keywords of a schema, and of its nested schemas, into their
+// Swagger 2.0 forms:
+//   - the references to components/schemas point to the definitions
+//   - nullable becomes the x-nullable extension
+//   - the named examples become the x-examples extension, since a Swagger 2.0 schema has no examples field
+//   - the discriminator object is reduced to its property name
+//
+// oneOf and anyOf are kept as they are.
+func toSwagger2Schema(element interface{}) interface{} {
+	schema, ok := element.(yaml.MapSlice)
+	if !ok {
+		return element
+	}
+	converted := make(yaml.MapSlice, 0, len(schema))
+	for _, field := range schema {
+		switch field.Key {
+		case "$ref":
+			if ref, ok := field.Value.(string); ok && strings.HasPrefix(ref, oas3SchemaRefPrefix) {
+				field.Value = swaggerDefinitionRefPrefix + strings.TrimPrefix(ref, oas3SchemaRefPrefix)
+			}
+		case "nullable":
+			field.Key = "x-nullable"
+		case "examples":
+			field.Key = xExamples
+		case "discriminator":
+			if discriminator, ok := field.Value.(yaml.MapSlice); ok {
+				field.Value, _ = lookForField(discriminator, "propertyName")
+			}
+		case "properties", "patternProperties":
+			if properties, ok := field.Value.(yaml.MapSlice); ok {
+				field.Value = toSwagger2Schemas(properties)
+			}
+		case "allOf", "oneOf", "anyOf", "items":
+			if schemas, ok := field.Value.([]interface{}); ok {
+				for i, item := range schemas {
+					schemas[i] = toSwagger2Schema(item)
+				}
+				break
+			}
+			field.Value = toSwagger2Schema(field.Value)
+		case "additionalProperties", "additionalItems", "not", "propertyNames":
+			field.Value = toSwagger2Schema(field.Value)
+		}
+		converted = append(converted, field)
+	}
+	return converted
+}
+
+// lookForField returns the value of the field of a yaml map
+func lookForField(fields yaml.MapSlice, key string) (interface{}, bool) {
+	for _, field := range fields {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+	return nil, false
+}
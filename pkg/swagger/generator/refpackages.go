@@ -0,0 +1,209 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-openapi/swag"
+	"gopkg.in/yaml.v2"
+)
+
+// WithRefPackages rewrites the $refs to the definitions of the other spec files mapped to a KCL package by
+// refPackages, so that the definitions are imported from the package the other spec is generated into,
+// instead of being bundled into the generated models. The spec files are mapped by their path, relative to
+// the working directory or absolute. The amended spec is written to a temporary file.
+//
+// For instance, when "common.yaml" is mapped to the "common" package, {"$ref": "common.yaml#/definitions/Owner"}
+// is typed common.Owner, imported from the common/owner.k module generated for common.yaml. The modules are named
+// by the file naming rules of lang, which defaults to the KCL language.
+func WithRefPackages(specPath string, refPackages map[string]string, lang *LanguageOpts) (string, error) {
+	if len(refPackages) == 0 {
+		return specPath, nil
+	}
+	packages := make(map[string]string, len(refPackages))
+	for file, pkg := range refPackages {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return "", err
+		}
+		packages[abs] = pkg
+	}
+	yamlDoc, err := swag.YAMLData(specPath)
+	if err != nil {
+		return "", err
+	}
+	if lang == nil {
+		lang = DefaultLanguageFunc()
+	}
+	yamlDoc, amended := toRefImports(yamlDoc, filepath.Dir(specPath), packages, lang)
+	if !amended {
+		return specPath, nil
+	}
+	out, err := yaml.Marshal(yamlDoc)
+	if err != nil {
+		return "", err
+	}
+	tmpFile, err := os.CreateTemp("", filepath.Base(specPath))
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(tmpFile.Name(), out, 0); err != nil {
+		return "", err
+	}
+	return tmpFile.Name(), nil
+}
+
+// toRefImports replaces the $refs to the mapped spec files found in the document element with the
+// x-kcl-type extension importing the referenced definitions. It returns the amended element and
+// whether any $ref was replaced.
+func toRefImports(element interface{}, dir string, packages map[string]string, lang *LanguageOpts) (interface{}, bool) {
+	amended := false
+	switch value := element.(type) {
+	case yaml.MapSlice:
+		for i, item := range value {
+			if item.Key == "$ref" {
+				if ext, ok := refImport(item.Value, dir, packages, lang); ok {
+					value[i] = yaml.MapItem{Key: xKclType, Value: ext}
+					amended = true
+				}
+				continue
+			}
+			var changed bool
+			if value[i].Value, changed = toRefImports(item.Value, dir, packages, lang); changed {
+				amended = true
+			}
+		}
+		return value, amended
+	case []interface{}:
+		for i, item := range value {
+			var changed bool
+			if value[i], changed = toRefImports(item, dir, packages, lang); changed {
+				amended = true
+			}
+		}
+		return value, amended
+	}
+	return element, false
+}
+
+// refImport returns the x-kcl-type extension importing the definition designated by a $ref to a mapped spec file.
+// A dotted definition name, such as "base.Category", designates the Category schema of the base subpackage.
+func refImport(ref interface{}, dir string, packages map[string]string, lang *LanguageOpts) (yaml.MapSlice, bool) {
+	s, ok := ref.(string)
+	if !ok {
+		return nil, false
+	}
+	idx := strings.Index(s, "#")
+	if idx <= 0 || strings.Contains(s[:idx], "://") {
+		return nil, false
+	}
+	pkg, ok := packages[filepath.Join(dir, filepath.FromSlash(s[:idx]))]
+	if !ok {
+		return nil, false
+	}
+	tokens := strings.Split(s[idx+1:], "/")
+	name := strings.NewReplacer("~1", "/", "~0", "~").Replace(tokens[len(tokens)-1])
+	if i := strings.LastIndex(name, "."); i != -1 {
+		pkg += "." + name[:i]
+		name = name[i+1:]
+	}
+	module := lang.MangleFileName(pascalize(name))
+	return yaml.MapSlice{
+		{Key: "type", Value: pascalize(name)},
+		{Key: "import", Value: yaml.MapSlice{
+			{Key: "package", Value: pkg + "." + module},
+			{Key: "alias", Value: module},
+		}},
+	}, true
+}
+
+// WithRebasedRefs rewrites the $refs to the other spec files, which are relative to the directory of the spec,
+// into paths relative to the temporary directory the spec is amended into by the preprocessing, so that they are
+// still resolved from the amended spec. The other spec files are bundled into the models by the flattening. The
+// amended spec is written to a temporary file.
+//
+// For instance, {"$ref": "./common.yaml#/Address"} in /specs/pet.yaml is rewritten into
+// {"$ref": "../specs/common.yaml#/Address"} when the temporary directory is /tmp.
+func WithRebasedRefs(specPath string) (string, error) {
+	from, err := filepath.Abs(filepath.Dir(specPath))
+	if err != nil {
+		return "", err
+	}
+	to, err := filepath.Abs(os.TempDir())
+	if err != nil {
+		return "", err
+	}
+	if from == to {
+		return specPath, nil
+	}
+	yamlDoc, err := swag.YAMLData(specPath)
+	if err != nil {
+		return "", err
+	}
+	if !rebaseRefs(yamlDoc, from, to) {
+		return specPath, nil
+	}
+	out, err := yaml.Marshal(yamlDoc)
+	if err != nil {
+		return "", err
+	}
+	tmpFile, err := os.CreateTemp("", filepath.Base(specPath))
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(tmpFile.Name(), out, 0); err != nil {
+		return "", err
+	}
+	return tmpFile.Name(), nil
+}
+
+// rebaseRefs rewrites in place the $refs to the other spec files found in the document element, from paths
+// relative to the from directory to paths relative to the to directory. It returns whether any $ref was rewritten.
+func rebaseRefs(element interface{}, from, to string) bool {
+	amended := false
+	switch value := element.(type) {
+	case yaml.MapSlice:
+		for i, item := range value {
+			if item.Key == "$ref" {
+				if ref, ok := rebaseRef(item.Value, from, to); ok {
+					value[i].Value = ref
+					amended = true
+				}
+				continue
+			}
+			if rebaseRefs(item.Value, from, to) {
+				amended = true
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if rebaseRefs(item, from, to) {
+				amended = true
+			}
+		}
+	}
+	return amended
+}
+
+// rebaseRef rebases a $ref to another spec file from the from directory to the to directory.
+// The local $refs, the URLs and the absolute paths are left as they are. The path is made absolute when it
+// can't be made relative to the to directory, e.g. on another volume.
+func rebaseRef(ref interface{}, from, to string) (string, bool) {
+	s, ok := ref.(string)
+	if !ok || s == "" || strings.HasPrefix(s, "#") || strings.Contains(s, "://") {
+		return "", false
+	}
+	file, pointer := s, ""
+	if idx := strings.Index(s, "#"); idx != -1 {
+		file, pointer = s[:idx], s[idx:]
+	}
+	if filepath.IsAbs(filepath.FromSlash(file)) {
+		return "", false
+	}
+	rebased := filepath.Join(from, filepath.FromSlash(file))
+	if rel, err := filepath.Rel(to, rebased); err == nil {
+		rebased = rel
+	}
+	return filepath.ToSlash(rebased) + pointer, true
+}
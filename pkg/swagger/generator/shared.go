@@ -24,6 +24,7 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 	"text/template"
@@ -57,6 +58,28 @@ func DefaultSectionOpts(gen *GenOpts) {
 				FileName: "{{ (snakize (pascalize (.Name))) }}.k",
 			},
 		}
+		if gen.FileNaming != "" {
+			sec.Models[0].FileName = gen.FileNaming
+			sec.Models[0].keepFileName = true
+		}
+	}
+	if sec.Index.Source == "" {
+		sec.Index = TemplateOpts{
+			Name:     "index",
+			Source:   "asset:index",
+			Target:   "{{ joinFilePath .Target (toFilePath .Package) }}",
+			FileName: "{{ .Name }}.k",
+		}
+	}
+	if sec.Mod.Source == "" {
+		sec.Mod = TemplateOpts{
+			Name:       "kcl-mod",
+			Source:     "asset:kclmod",
+			Target:     "{{ .Target }}",
+			FileName:   "kcl.mod",
+			SkipExists: true,
+			SkipFormat: true,
+		}
 	}
 	gen.Sections = sec
 }
@@ -69,18 +92,141 @@ type TemplateOpts struct {
 	FileName   string `mapstructure:"file_name"`
 	SkipExists bool   `mapstructure:"skip_exists"`
 	SkipFormat bool   `mapstructure:"skip_format"`
+
+	// keepFileName keeps the rendered file name as is, instead of turning it into a snake case file name
+	keepFileName bool
 }
 
 // SectionOpts allows for specifying options to customize the templates used for generation
 type SectionOpts struct {
 	Models []TemplateOpts `mapstructure:"models"`
+	// Index is the template of the index of the models package, rendered once with all the models
+	Index TemplateOpts `mapstructure:"index"`
+	// Mod is the template of the kcl.mod declaring the generated package, rendered once in the target directory
+	Mod TemplateOpts `mapstructure:"mod"`
 }
 
+const (
+	// CheckOptionalSkipNone skips the checks of the optional properties when they are None
+	CheckOptionalSkipNone = "skip-none"
+	// CheckOptionalRequire makes the checks of the optional properties forbid None
+	CheckOptionalRequire = "require"
+
+	// AnonNamingConcat names the schemas of the anonymous objects after the path to them, e.g. PetOwnerAddress
+	AnonNamingConcat = "concat"
+	// AnonNamingShort names the schemas of the anonymous objects after their property, e.g. Address
+	AnonNamingShort = "short"
+	// AnonNamingHash names the schemas of the anonymous objects after a short hash of their content, e.g. Anon1b2c3d4e
+	AnonNamingHash = "hash"
+	// DefaultAdditionalPropsName is the default suffix naming the values of the additional properties of an object
+	DefaultAdditionalPropsName = "AdditionalProperties"
+)
+
 // GenOpts the options for the generator
 type GenOpts struct {
-	ValidateSpec bool
-	FlattenOpts  *analysis.FlattenOpts
-	KeepOrder    bool
+	ValidateSpec       bool
+	FlattenOpts        *analysis.FlattenOpts
+	KeepOrder          bool
+	TrimUnusedImports  bool
+	SourceComments     bool
+	WarnUnknownFormats bool
+	Summary            bool
+	SummaryFile        string
+	// MixinThreshold is the number of definitions from which a group of identical properties they
+	// share is extracted into a mixin, 0 disables the extraction
+	MixinThreshold int
+	// CheckOptional tells how the checks of the optional properties handle None values,
+	// either CheckOptionalSkipNone (default) or CheckOptionalRequire
+	CheckOptional string
+	// ReadOnlyOptional renders the read-only properties as optional
+	ReadOnlyOptional bool
+	// DropReadOnly leaves the read-only properties out of the generated schemas
+	DropReadOnly bool
+	// ExcludeWriteOnly leaves the write-only properties, such as passwords, out of the generated schemas,
+	// including the required ones
+	ExcludeWriteOnly bool
+	// GVKRegistry generates a registry mapping the apiVersion and kind of the kubernetes resources
+	// to the generated schemas
+	GVKRegistry bool
+	// GenIndex generates an index module in the models package, importing all the models
+	// and exporting the ones of the subpackages under a single name
+	GenIndex bool
+	// Workers is the number of models rendered concurrently, defaults to the number of CPUs
+	Workers int
+	// GenMod generates a kcl.mod declaring the generated package in the target directory
+	GenMod bool
+	// ModName is the name of the package declared by the kcl.mod, defaults to the name of the target directory
+	ModName string
+	// Force overwrites the existing kcl.mod
+	Force bool
+	// ModelNames are the names of the definitions to generate, along with the definitions they reference,
+	// all the definitions are generated when empty
+	ModelNames []string
+	// Clean removes the KCL files of the models package before generating, so that no stale model is left behind.
+	// The other files are kept, and a models package resolving to the target directory is not cleaned
+	Clean bool
+	// NoOverwrite fails the generation instead of overwriting an existing file
+	NoOverwrite bool
+	// SingleFile renders all the models of a package into a single file named after the package,
+	// instead of one file per model
+	SingleFile bool
+	// DryRun prints the path and the content of the generated files to the standard output
+	// instead of writing them, leaving the target untouched
+	DryRun bool
+	// NameFromTitle names the generated schemas after the title of their definition
+	NameFromTitle bool
+	// OpenAPIVersion forces the version of the spec, either OpenAPIVersion2 or OpenAPIVersion3,
+	// which is otherwise detected from the spec
+	OpenAPIVersion string
+	// WithExamples renders the named examples of the schemas, listed by the OpenAPI 3.0 "examples" field,
+	// or by the x-examples extension of a Swagger 2.0 spec
+	WithExamples bool
+	// GenExamples renders the example of each definition as a commented-out instance of its schema,
+	// following the schema
+	GenExamples bool
+	// QuantityTypes types the kubernetes quantities, the values of the quantity format or of the properties named
+	// by QuantityFields, as a number multiplier of the units module or its string form checked against the
+	// pattern of the quantities
+	QuantityTypes bool
+	// QuantityFields are the names of the properties whose string or x-kubernetes-int-or-string values, or the
+	// values of their arrays and maps, are quantities when QuantityTypes is set, e.g. cpu or limits
+	QuantityFields []string
+	// FormatRangeChecks checks the values of the sized integer formats, such as int8 or uint32, to be within
+	// the range of the format. The explicit minimum and maximum are kept when they are tighter than the range
+	FormatRangeChecks bool
+	// DateAsStrCheck checks the strings of the date and date-time formats against the anchored patterns of
+	// the RFC3339 full-date and date-time
+	DateAsStrCheck bool
+	// FormatPatternChecks checks the strings of the formats with a well-known pattern, such as uuid or email,
+	// against their pattern, including the array items and the map values
+	FormatPatternChecks bool
+	// NonEmptyStrings checks the required strings without an explicit minLength to be non-empty
+	NonEmptyStrings bool
+	// StrictEnumTypes fails the generation when an enum value does not match the type of its schema,
+	// which is otherwise only warned about
+	StrictEnumTypes bool
+	// Strict fails the generation when a warning is raised, rather than generating models which may differ from
+	// the spec. The models are not written when the warnings are raised while planning them.
+	Strict bool
+	// TemplateDir is a directory of templates overriding the embedded ones of the same name, including the
+	// protected ones, e.g. a docstring.gotmpl file overrides the template of the docstrings of the schemas
+	TemplateDir string
+	// FileNaming is the template of the names of the files the models are rendered into, evaluated with the name
+	// and the package of each model, e.g. {{ camelize .Name }}.k. Defaults to the snake case name of the model
+	FileNaming string
+	// AnonNaming tells how the schemas of the anonymous objects are named, either AnonNamingConcat (default),
+	// AnonNamingShort or AnonNamingHash. The names colliding with another schema are suffixed with a number
+	AnonNaming string
+	// AdditionalPropsName is the suffix appended to the name of an object allowing any additional property to
+	// name the values of its additional properties, defaults to AdditionalProperties. A warning is raised when
+	// the name of the values is the name of a property of the same model
+	AdditionalPropsName string
+	// NoMangleFileSuffix leaves the names of the generated files ending with an OS, an arch or test, such as
+	// deployment_windows.k, as they are. They are otherwise suffixed with _swagger, e.g. deployment_windows_swagger.k
+	NoMangleFileSuffix bool
+	// NoMangleDirName leaves the package directories named vendor or internal as they are.
+	// They are otherwise suffixed with _swagger, e.g. vendor_swagger
+	NoMangleDirName bool
 
 	Spec              string
 	ModelPackage      string
@@ -90,6 +236,32 @@ type GenOpts struct {
 	FlagStrategy      string
 	CompatibilityMode string
 	Copyright         string
+	// RefPackages maps the other spec files referenced by the spec to the KCL package they are generated into,
+	// so that their definitions are imported from that package instead of being bundled into the models
+	RefPackages map[string]string
+	// UnionMapping maps a format, or a boolean vendor extension (x-...), to a kcl union type
+	UnionMapping map[string]string
+	// Warnings collects the warnings raised during the generation, see Generate
+	Warnings []Warning
+
+	summary *GenSummary
+	// warnings records the warnings raised during the generation
+	warnings *warningRecorder
+	// files collects the generated files in memory instead of writing them, see GenerateModels
+	files *generatedFiles
+	// amendedSpecs are the temporary files the spec is amended into by the preprocessing, see amendSpec,
+	// originalSpec being the spec before
+	amendedSpecs []string
+	originalSpec string
+	// templates is the repository of the templates of the generation, the embedded ones along with the overrides
+	// of the TemplateDir and the template files of the sections, see setTemplates
+	templates *Repository
+}
+
+// writesFiles tells if the generated files are written into the target directory,
+// rather than printed in dry run or collected in memory
+func (g *GenOpts) writesFiles() bool {
+	return !g.DryRun && g.files == nil
 }
 
 // CheckOpts carries out some global consistency checks on options.
@@ -105,6 +277,50 @@ func (g *GenOpts) CheckOpts() error {
 		}
 	}
 
+	switch g.CheckOptional {
+	case "", CheckOptionalSkipNone, CheckOptionalRequire:
+	default:
+		return fmt.Errorf("unknown check-optional mode %s, expect %s or %s", g.CheckOptional, CheckOptionalSkipNone, CheckOptionalRequire)
+	}
+
+	switch g.AnonNaming {
+	case "", AnonNamingConcat, AnonNamingShort, AnonNamingHash:
+	default:
+		return fmt.Errorf("unknown anonymous naming strategy %s, expect %s, %s or %s", g.AnonNaming, AnonNamingConcat, AnonNamingShort, AnonNamingHash)
+	}
+
+	if g.AdditionalPropsName != "" && !identifierRegexp.MatchString("_"+g.AdditionalPropsName) {
+		return fmt.Errorf("invalid additional properties name %s, expect letters, digits or underscores", g.AdditionalPropsName)
+	}
+
+	if g.Clean && g.NoOverwrite {
+		return errors.New("the clean and no-overwrite options are mutually exclusive")
+	}
+
+	switch g.OpenAPIVersion {
+	case "", OpenAPIVersion2, OpenAPIVersion3:
+	default:
+		return fmt.Errorf("unknown openapi version %s, expect %s or %s", g.OpenAPIVersion, OpenAPIVersion2, OpenAPIVersion3)
+	}
+
+	if g.TemplateDir != "" {
+		if info, err := os.Stat(g.TemplateDir); err != nil {
+			return fmt.Errorf("could not locate the template directory %s: %v", g.TemplateDir, err)
+		} else if !info.IsDir() {
+			return fmt.Errorf("the template directory %s is not a directory", g.TemplateDir)
+		}
+	}
+
+	if err := g.checkTemplateFiles(); err != nil {
+		return err
+	}
+
+	if g.FileNaming != "" {
+		if err := g.checkFileNaming(); err != nil {
+			return fmt.Errorf("invalid file naming %q: %v", g.FileNaming, err)
+		}
+	}
+
 	// check the oai spec file exists
 	pth, err := findSwaggerSpec(g.Spec)
 	if err != nil {
@@ -126,6 +342,8 @@ func (g *GenOpts) EnsureDefaults() error {
 	if g.LanguageOpts == nil {
 		g.LanguageOpts = DefaultLanguageFunc()
 	}
+	g.LanguageOpts.NoMangleFileSuffix = g.NoMangleFileSuffix
+	g.LanguageOpts.NoMangleDirName = g.NoMangleDirName
 
 	// default section: set default section name for each section. only model section is used
 	DefaultSectionOpts(g)
@@ -192,12 +410,7 @@ func (g *GenOpts) location(t *TemplateOpts, data interface{}) (string, string, e
 		return "", "", err
 	}
 
-	d := struct {
-		Name, Package, Target string
-		Tags                  []string
-		UseTags               bool
-		Context               interface{}
-	}{
+	d := locationData{
 		Name:    name,
 		Package: pkg,
 		Target:  g.Target,
@@ -214,14 +427,45 @@ func (g *GenOpts) location(t *TemplateOpts, data interface{}) (string, string, e
 	if e := fNameTpl.Execute(&fNameBuf, d); e != nil {
 		return "", "", e
 	}
+	if t.keepFileName {
+		return pthBuf.String(), fNameBuf.String(), nil
+	}
 	return pthBuf.String(), fileName(fNameBuf.String()), nil
 }
 
+// locationData is the data the target and the file name templates are evaluated with
+type locationData struct {
+	Name, Package, Target string
+	Tags                  []string
+	UseTags               bool
+	Context               interface{}
+}
+
+// checkFileNaming checks the file naming template to be evaluated into the name of a file
+func (g *GenOpts) checkFileNaming() error {
+	lang := g.LanguageOpts
+	if lang == nil {
+		lang = DefaultLanguageFunc()
+	}
+	tpl, err := template.New("file-naming").Funcs(FuncMapFunc(lang)).Parse(g.FileNaming)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, locationData{Name: "Pet", Package: g.ModelPackage, Target: g.Target, Context: GenDefinition{}}); err != nil {
+		return err
+	}
+	if name := buf.String(); name == "" || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("the file name %q of the model Pet is not the name of a file", name)
+	}
+	return nil
+}
+
 func (g *GenOpts) render(t *TemplateOpts, data interface{}) ([]byte, error) {
 	var templ *template.Template
 
-	if strings.HasPrefix(strings.ToLower(t.Source), "asset:") {
-		tt, err := templates.Get(strings.TrimPrefix(t.Source, "asset:"))
+	if isAsset(t.Source) {
+		tt, err := g.templates.Get(strings.TrimPrefix(t.Source, "asset:"))
 		if err != nil {
 			return nil, err
 		}
@@ -231,7 +475,7 @@ func (g *GenOpts) render(t *TemplateOpts, data interface{}) ([]byte, error) {
 	if templ == nil {
 		// try to load from repository (and enable dependencies)
 		name := swag.ToJSONName(strings.TrimSuffix(t.Source, ".gotmpl"))
-		tt, err := templates.Get(name)
+		tt, err := g.templates.Get(name)
 		if err == nil {
 			templ = tt
 		}
@@ -260,19 +504,26 @@ func (g *GenOpts) write(t *TemplateOpts, data interface{}) error {
 		return fmt.Errorf("failed to resolve template location for template %s: %v", t.Name, err)
 	}
 
-	if t.SkipExists && fileExists(dir, fname) {
+	if t.SkipExists && g.writesFiles() && fileExists(dir, fname) {
 		debugLog("skipping generation of %s because it already exists and skip_exist directive is set for %s",
 			filepath.Join(dir, fname), t.Name)
 		return nil
 	}
 
+	if g.NoOverwrite && g.writesFiles() && fileExists(dir, fname) {
+		return fmt.Errorf("the file %s already exists and overwriting is disabled", filepath.Join(dir, fname))
+	}
+
 	log.Printf("creating generated file %q in %q as %s", fname, dir, t.Name)
 	content, err := g.render(t, data)
 	if err != nil {
 		return fmt.Errorf("failed rendering template data for %s: %v", t.Name, err)
 	}
+	if g.TrimUnusedImports {
+		content = trimUnusedImports(content)
+	}
 
-	if dir != "" {
+	if dir != "" && g.writesFiles() {
 		_, exists := os.Stat(dir)
 		if os.IsNotExist(exists) {
 			debugLog("creating directory %q for \"%s\"", dir, t.Name)
@@ -292,6 +543,9 @@ func (g *GenOpts) write(t *TemplateOpts, data interface{}) error {
 		formatted, err = g.LanguageOpts.FormatContent(filepath.Join(dir, fname), content)
 		if err != nil {
 			log.Printf("source formatting failed on template-generated source (%q for %s). Check that your template produces valid code", filepath.Join(dir, fname), t.Name)
+			if !g.writesFiles() {
+				return fmt.Errorf("source formatting on generated source %q failed: %v", t.Name, err)
+			}
 			writeerr = ioutil.WriteFile(filepath.Join(dir, fname), content, 0644)
 			if writeerr != nil {
 				return fmt.Errorf("failed to write (unformatted) file %q in %q: %v", fname, dir, writeerr)
@@ -301,9 +555,19 @@ func (g *GenOpts) write(t *TemplateOpts, data interface{}) error {
 		}
 	}
 
-	writeerr = ioutil.WriteFile(filepath.Join(dir, fname), formatted, 0644)
-	if writeerr != nil {
-		return fmt.Errorf("failed to write file %q in %q: %v", fname, dir, writeerr)
+	switch {
+	case g.files != nil:
+		g.files.add(filepath.Join(dir, fname), formatted)
+	case g.DryRun:
+		fmt.Printf("# %s\n%s\n", filepath.Join(dir, fname), formatted)
+	default:
+		writeerr = ioutil.WriteFile(filepath.Join(dir, fname), formatted, 0644)
+		if writeerr != nil {
+			return fmt.Errorf("failed to write file %q in %q: %v", fname, dir, writeerr)
+		}
+	}
+	if g.summary != nil {
+		g.summary.addFile(countChecks(formatted))
 	}
 	return err
 }
@@ -323,8 +587,21 @@ func (g *GenOpts) renderDefinition(gg *GenDefinition) error {
 	return nil
 }
 
-func (g *GenOpts) setTemplates() {
-	templates.LoadDefaults()
+// setTemplates builds the repository of the templates of the generation, so that the overrides of a generation
+// are not rendered by the next ones
+func (g *GenOpts) setTemplates() error {
+	lang := g.LanguageOpts
+	if lang == nil {
+		lang = DefaultLanguageFunc()
+	}
+	g.templates = NewRepository(FuncMapFunc(lang))
+	g.templates.LoadDefaults()
+	if g.TemplateDir != "" {
+		if err := g.templates.LoadDir(g.TemplateDir); err != nil {
+			return fmt.Errorf("could not load the templates of %s: %v", g.TemplateDir, err)
+		}
+	}
+	return g.loadTemplateFiles()
 }
 
 func fileExists(target, name string) bool {
@@ -332,19 +609,71 @@ func fileExists(target, name string) bool {
 	return !os.IsNotExist(err)
 }
 
-func gatherModels(specDoc *loads.Document) (map[string]spec.Schema, error) {
+// gatherModels collects the definitions to generate: all of them, or the named ones along with the definitions
+// they reference, directly or not, so that the generated models are complete.
+func gatherModels(specDoc *loads.Document, modelNames []string) (map[string]spec.Schema, error) {
 	models := make(map[string]spec.Schema)
 	defs := specDoc.Spec().Definitions
-	for k, v := range defs {
-		models[k] = v
+	if len(modelNames) == 0 {
+		for k, v := range defs {
+			models[k] = v
+		}
+		return models, nil
+	}
+	var unknownModels []string
+	for _, k := range modelNames {
+		if _, ok := defs[k]; !ok {
+			unknownModels = append(unknownModels, k)
+		}
+	}
+	if len(unknownModels) > 0 {
+		return nil, fmt.Errorf("unknown models: %s", strings.Join(unknownModels, ", "))
+	}
+	pending := append([]string(nil), modelNames...)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if _, ok := models[name]; ok {
+			continue
+		}
+		sch := defs[name]
+		models[name] = sch
+		pending = append(pending, referencedDefinitions(name, sch, defs)...)
 	}
 	return models, nil
 }
 
+// referencedDefinitions returns the names of the definitions referenced by the $refs of a definition,
+// which are all local to the flattened spec
+func referencedDefinitions(name string, sch spec.Schema, defs spec.Definitions) []string {
+	analyzed := analysis.New(&spec.Swagger{SwaggerProps: spec.SwaggerProps{
+		Definitions: spec.Definitions{name: sch},
+	}})
+	var names []string
+	for _, ref := range analyzed.AllDefinitionReferences() {
+		if !strings.HasPrefix(ref, "#/definitions/") {
+			continue
+		}
+		def := strings.NewReplacer("~1", "/", "~0", "~").Replace(strings.TrimPrefix(ref, "#/definitions/"))
+		if _, ok := defs[def]; ok {
+			names = append(names, def)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func trimBOM(in string) string {
 	return strings.Trim(in, "\xef\xbb\xbf")
 }
 
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// escapeJSONPointer escapes a single reference token of a JSON pointer
+func escapeJSONPointer(token string) string {
+	return jsonPointerEscaper.Replace(token)
+}
+
 // gatherExtraSchemas produces a sorted list of extra schemas.
 //
 // ExtraSchemas are inlined types rendered in the same model file.
@@ -362,26 +691,170 @@ func gatherExtraSchemas(extraMap map[string]GenSchema) (extras GenSchemaList) {
 	return
 }
 
-func sharedValidationsFromSchema(v spec.Schema, sg schemaGenContext) (sh sharedValidations) {
+func sharedValidationsFromSchema(v spec.Schema, sg schemaGenContext) (sh sharedValidations, err error) {
 	sh = sharedValidations{
 		Maximum:          v.Maximum,
 		ExclusiveMaximum: v.ExclusiveMaximum,
 		Minimum:          v.Minimum,
 		ExclusiveMinimum: v.ExclusiveMinimum,
 		MaxLength:        v.MaxLength,
-		MinLength:        v.MinLength,
+		MinLength:        nonZeroBound(v.MinLength),
 		Pattern:          v.Pattern,
 		MaxItems:         v.MaxItems,
-		MinItems:         v.MinItems,
+		MinItems:         nonZeroBound(v.MinItems),
 		UniqueItems:      v.UniqueItems,
 		MultipleOf:       v.MultipleOf,
 		Enum:             v.Enum,
 	}
-	sh.pruneEnums(sg)
+	if keys, ok := propertyNamesEnum(&v); ok {
+		for _, key := range keys {
+			sh.KeyEnum = append(sh.KeyEnum, key)
+		}
+	}
+	sh.KeyPattern, sh.KeyMinLength, sh.KeyMaxLength = propertyNamesValidations(&v)
+	sh.MinProperties, sh.MaxProperties = propertiesBounds(&v)
+	err = sh.pruneEnums(sg)
 	return
 }
 
+// nonZeroBound omits a zero lower bound, since checking a length to be >= 0 is a no-op
+func nonZeroBound(bound *int64) *int64 {
+	if bound != nil && *bound == 0 {
+		return nil
+	}
+	return bound
+}
+
 func importAlias(pkg string) string {
 	_, k := path.Split(pkg)
 	return k
 }
+
+var (
+	importLineRegexp      = regexp.MustCompile(`^import\s+([\w.]+)(?:\s+as\s+(\w+))?\s*$`)
+	memberAliasLineRegexp = regexp.MustCompile(`^(_\w+)\s*=\s*(\w+)\.\w+\s*$`)
+)
+
+// trimUnusedImports removes from a generated KCL file the import statements whose module
+// is never referenced in the code. Module member aliases such as `_regex_match = regex.match`
+// are removed as well when the alias itself is unused.
+//
+// References found in string literals or comments (e.g. docstrings) do not count as usages,
+// except in the expressions interpolated in the strings.
+func trimUnusedImports(content []byte) []byte {
+	lines := strings.Split(string(content), "\n")
+	code := strings.Split(stripStringsAndComments(string(content)), "\n")
+	removed := make(map[int]bool)
+
+	isReferenced := func(name string, suffix string) bool {
+		ref := regexp.MustCompile(`(^|[^\w.$])` + regexp.QuoteMeta(name) + suffix)
+		for i, line := range code {
+			if removed[i] || importLineRegexp.MatchString(line) {
+				continue
+			}
+			if m := memberAliasLineRegexp.FindStringSubmatch(line); m != nil && m[1] == name {
+				continue
+			}
+			if ref.MatchString(line) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// drop unused member aliases first, they may be the only usage of a module
+	for i, line := range code {
+		if m := memberAliasLineRegexp.FindStringSubmatch(line); m != nil && !isReferenced(m[1], `\b`) {
+			removed[i] = true
+		}
+	}
+	for i, line := range code {
+		m := importLineRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		name := m[2]
+		if name == "" {
+			name = m[1][strings.LastIndex(m[1], ".")+1:]
+		}
+		if !isReferenced(name, `\.`) {
+			debugLog("trimming unused import %q", strings.TrimSpace(lines[i]))
+			removed[i] = true
+		}
+	}
+	if len(removed) == 0 {
+		return content
+	}
+
+	result := make([]string, 0, len(lines)-len(removed))
+	for i, line := range lines {
+		if !removed[i] {
+			result = append(result, line)
+		}
+	}
+	return []byte(strings.Join(result, "\n"))
+}
+
+// stripStringsAndComments blanks out the string literals and comments of some KCL code, except
+// the expressions interpolated in the strings. Line breaks are preserved so that the result lines up with the original code.
+func stripStringsAndComments(code string) string {
+	out := []byte(code)
+	blank := func(i int) {
+		if out[i] != '\n' {
+			out[i] = ' '
+		}
+	}
+	for i := 0; i < len(out); i++ {
+		switch out[i] {
+		case '#':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				blank(i)
+			}
+		case '"', '\'':
+			quote := string(out[i : i+1])
+			if strings.HasPrefix(code[i:], strings.Repeat(quote, 3)) {
+				quote = strings.Repeat(quote, 3)
+			}
+			end := i + len(quote)
+			for end < len(code) && !strings.HasPrefix(code[end:], quote) {
+				if code[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end += len(quote)
+			if end > len(out) {
+				end = len(out)
+			}
+			// the expressions interpolated with ${...} in a string which is not raw are code
+			raw := i > 0 && (code[i-1] == 'r' || code[i-1] == 'R')
+			for ; i < end; i++ {
+				if !raw && strings.HasPrefix(code[i:end], "${") {
+					i = interpolationEnd(code[:end], i)
+					continue
+				}
+				blank(i)
+			}
+			i--
+		}
+	}
+	return string(out)
+}
+
+// interpolationEnd returns the index of the brace closing the interpolation starting at start
+// in some code, or the last index of the code when it is not closed
+func interpolationEnd(code string, start int) int {
+	depth := 0
+	for i := start + 2; i < len(code); i++ {
+		switch code[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return len(code) - 1
+}
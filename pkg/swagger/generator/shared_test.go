@@ -0,0 +1,125 @@
+package generator
+
+import "testing"
+
+func TestTrimUnusedImports(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		expect string
+	}{
+		{
+			name: "check-stripped",
+			input: `"""
+This file was generated by the KCL auto-gen tool. DO NOT EDIT.
+"""
+import regex
+_regex_match = regex.match
+
+
+schema Pet:
+    """
+    pet, the name is validated with regex.match
+
+    Attributes
+    ----------
+    name : str, default is Undefined, optional
+    """
+
+
+    # regex.match(name, r"^[a-z]+$")
+    name?: str
+`,
+			expect: `"""
+This file was generated by the KCL auto-gen tool. DO NOT EDIT.
+"""
+
+
+schema Pet:
+    """
+    pet, the name is validated with regex.match
+
+    Attributes
+    ----------
+    name : str, default is Undefined, optional
+    """
+
+
+    # regex.match(name, r"^[a-z]+$")
+    name?: str
+`,
+		},
+		{
+			name: "check-kept",
+			input: `import regex
+_regex_match = regex.match
+
+
+schema Pet:
+    name?: str
+
+
+    check:
+        _regex_match(str(name), r"^[a-z]+$") if name
+`,
+			expect: `import regex
+_regex_match = regex.match
+
+
+schema Pet:
+    name?: str
+
+
+    check:
+        _regex_match(str(name), r"^[a-z]+$") if name
+`,
+		},
+		{
+			name: "aliased-import",
+			input: `import base.category as baseCategory
+import base.tag
+
+
+schema Pet:
+    category?: baseCategory.Category
+    note?: str = "tag.Tag"
+`,
+			expect: `import base.category as baseCategory
+
+
+schema Pet:
+    category?: baseCategory.Category
+    note?: str = "tag.Tag"
+`,
+		},
+		{
+			name: "interpolated-import",
+			input: `import units
+import base.tag
+
+
+schema Pet:
+    size?: int
+    label?: str = "${units.to_Ki(1024)} of {size}"
+    pattern?: str = r"${tag.Tag}"
+`,
+			expect: `import units
+
+
+schema Pet:
+    size?: int
+    label?: str = "${units.to_Ki(1024)} of {size}"
+    pattern?: str = r"${tag.Tag}"
+`,
+		},
+	}
+
+	for _, testcase := range cases {
+		t.Run(testcase.name, func(t *testing.T) {
+			got := string(trimUnusedImports([]byte(testcase.input)))
+			if got != testcase.expect {
+				t.Fatalf("unexpected output, expect:\n%s\ngot:\n%s\n", testcase.expect, got)
+			}
+		})
+	}
+}
@@ -15,16 +15,20 @@
 package generator
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/go-openapi/analysis"
 	swaggererrors "github.com/go-openapi/errors"
 	"github.com/go-openapi/loads"
+	"github.com/go-openapi/spec"
 	"github.com/go-openapi/strfmt"
 	"github.com/go-openapi/swag"
 	"github.com/go-openapi/validate"
@@ -95,12 +99,71 @@ func (g *GenOpts) flattenSpec() (*loads.Document, error) {
 	return specDoc, nil
 }
 
+// amendSpec makes the spec amended by a preprocessing step, written to a temporary file, the spec of the
+// generation. The amended specs are removed once the generation is done, see removeAmendedSpecs.
+func (g *GenOpts) amendSpec(specPath string) {
+	if specPath == g.Spec {
+		return
+	}
+	if g.amendedSpecs == nil {
+		g.originalSpec = g.Spec
+	}
+	g.amendedSpecs = append(g.amendedSpecs, specPath)
+	g.Spec = specPath
+}
+
+// removeAmendedSpecs removes the temporary files of the amended specs and restores the spec of the generation
+func (g *GenOpts) removeAmendedSpecs() {
+	for _, specPath := range g.amendedSpecs {
+		if err := os.Remove(specPath); err != nil {
+			debugLog("failed to remove the amended spec %s: %v", specPath, err)
+		}
+	}
+	if g.amendedSpecs != nil {
+		g.Spec = g.originalSpec
+		g.amendedSpecs = nil
+	}
+}
+
 func (g *GenOpts) analyzeSpec() (*loads.Document, *analysis.Spec, error) {
+	// preprocess: import the definitions of the other spec files mapped to a KCL package, instead of bundling them
+	specPath, err := WithRefPackages(g.Spec, g.RefPackages, g.LanguageOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	g.amendSpec(specPath)
+
+	// preprocess: rebase the $refs to the other spec files, relative to the directory of the spec,
+	// onto the temporary directory the spec is amended into
+	specPath, err = WithRebasedRefs(g.Spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	g.amendSpec(specPath)
+
+	// preprocess: convert an OpenAPI 3.0 spec down to a Swagger 2.0 spec
+	specPath, err = WithSwagger2(g.Spec, g.OpenAPIVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	g.amendSpec(specPath)
+
 	// preprocess: add x-order to properties
 	if g.KeepOrder {
-		g.Spec = WithXOrder(g.Spec, AddXOrderOnProperty)
+		specPath, err = WithXOrder(g.Spec, AddXOrderOnProperty)
+		if err != nil {
+			return nil, nil, err
+		}
+		g.amendSpec(specPath)
 	}
 
+	// preprocess: turn numeric exclusive bounds and boolean items into their Swagger 2.0 forms
+	specPath, err = withSwaggerKeywords(g.Spec, g.warnings)
+	if err != nil {
+		return nil, nil, err
+	}
+	g.amendSpec(specPath)
+
 	// load spec document and validate spec if needed
 	specDoc, err := g.loadSpec()
 	if err != nil {
@@ -113,10 +176,20 @@ func (g *GenOpts) analyzeSpec() (*loads.Document, *analysis.Spec, error) {
 		}
 	}
 
-	// preprocess: add x-order to maps in "default" & "example" fields
+	// preprocess: add x-order to maps in "default", "example" & "x-examples" fields
 	// this logic should run after spec validation, since x-extensions are not allowed on "default" & "example" fields
 	if g.KeepOrder {
-		g.Spec = WithXOrder(g.Spec, AddXOrderOnDefaultExample)
+		specPath, err = WithXOrder(g.Spec, AddXOrderOnDefaultExample)
+		if err != nil {
+			return nil, nil, err
+		}
+		g.amendSpec(specPath)
+	}
+
+	// the definitions of the spec, before the flattening bundles the ones of the other spec files
+	local := make(map[string]bool, len(specDoc.Spec().Definitions))
+	for name := range specDoc.Spec().Definitions {
+		local[name] = true
 	}
 
 	// flatten spec
@@ -125,6 +198,43 @@ func (g *GenOpts) analyzeSpec() (*loads.Document, *analysis.Spec, error) {
 		return nil, nil, err
 	}
 
+	// the allOf inheritance must not be cyclic, which would make the generation loop endlessly
+	if err := checkInheritanceCycles(specDoc.Spec()); err != nil {
+		return nil, nil, err
+	}
+
+	// preprocess: restore the x-order of the properties rewritten by the flattening
+	if g.KeepOrder {
+		if err := restoreXOrder(specDoc.Spec(), g.Spec); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// preprocess: restore the exact value of big integers, which JSON decoding rounds
+	if err := preserveIntegers(specDoc.Spec(), g.Spec); err != nil {
+		return nil, nil, err
+	}
+
+	// preprocess: inline the array definitions, which have no KCL schema
+	inlineArrayDefinitions(specDoc.Spec())
+
+	// preprocess: turn maps with a closed set of keys into objects
+	expandPropertyNames(specDoc.Spec())
+
+	// preprocess: name the definitions after their title
+	if g.NameFromTitle {
+		nameFromTitles(specDoc.Spec(), g.warnings)
+	}
+
+	// preprocess: name the definitions bundled from the other spec files like the local ones
+	nameBundledDefinitions(specDoc.Spec(), local)
+
+	// preprocess: move the discriminators declared by children onto their base type
+	liftChildDiscriminators(specDoc.Spec(), g.warnings)
+
+	// preprocess: extract the groups of properties shared by several definitions into mixins
+	extractMixins(specDoc.Spec(), g.MixinThreshold)
+
 	// analyze the spec
 	analyzed := analysis.New(specDoc.Spec())
 
@@ -172,31 +282,225 @@ func findSwaggerSpec(nm string) (string, error) {
 }
 
 // WithXOrder amends the spec to specify the order of some fields (such as property, default, example, ...). supports yaml documents only.
-func WithXOrder(specPath string, addXOrderFunc func(yamlDoc interface{}) interface{}) string {
+func WithXOrder(specPath string, addXOrderFunc func(yamlDoc interface{}) interface{}) (string, error) {
 	yamlDoc, err := swag.YAMLData(specPath)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
 	added := addXOrderFunc(yamlDoc)
 
 	out, err := yaml.Marshal(added)
 	if err != nil {
-		panic(err)
+		return "", err
+	}
+
+	tmpFile, err := os.CreateTemp("", filepath.Base(specPath))
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(tmpFile.Name(), out, 0); err != nil {
+		return "", err
 	}
+	return tmpFile.Name(), nil
+}
+
+// WithSwaggerKeywords amends the spec when it uses keywords of OpenAPI 3.1 or later JSON Schema drafts
+// which can't be decoded as Swagger 2.0:
+//   - the numeric form of "exclusiveMinimum" and "exclusiveMaximum": {"exclusiveMinimum": 0} is turned
+//     into {"minimum": 0, "exclusiveMinimum": true}
+//   - the boolean form of "items": {"items": true} is turned into {"items": {}} and {"items": false},
+//     which allows no item at all, into {"items": {}, "maxItems": 0}
+//   - "const": {"const": "v1"} is turned into the single-valued enum {"enum": ["v1"], "default": "v1"}.
+//     The enum and the default the const conflicts with are replaced, with a warning
+//
+// The spec path is returned unchanged when there is nothing to amend.
+func WithSwaggerKeywords(specPath string) (string, error) {
+	return withSwaggerKeywords(specPath, nil)
+}
 
+// withSwaggerKeywords amends the spec like WithSwaggerKeywords, the warnings being reported to the warning recorder
+func withSwaggerKeywords(specPath string, warnings *warningRecorder) (string, error) {
+	yamlDoc, err := swag.YAMLData(specPath)
+	if err != nil {
+		return "", err
+	}
+	yamlDoc, amended := toSwaggerKeywords(yamlDoc, warnings)
+	if !amended {
+		return specPath, nil
+	}
+	out, err := yaml.Marshal(yamlDoc)
+	if err != nil {
+		return "", err
+	}
 	tmpFile, err := os.CreateTemp("", filepath.Base(specPath))
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 	if err := os.WriteFile(tmpFile.Name(), out, 0); err != nil {
-		panic(err)
+		return "", err
+	}
+	return tmpFile.Name(), nil
+}
+
+// toSwaggerKeywords rewrites the numeric exclusive bounds and boolean items found in the document element.
+// It returns the amended element and whether any keyword was rewritten.
+func toSwaggerKeywords(element interface{}, warnings *warningRecorder) (interface{}, bool) {
+	amended := false
+	switch value := element.(type) {
+	case yaml.MapSlice:
+		for i, item := range value {
+			var changed bool
+			if value[i].Value, changed = toSwaggerKeywords(item.Value, warnings); changed {
+				amended = true
+			}
+		}
+		for _, bound := range [][2]string{{"exclusiveMinimum", "minimum"}, {"exclusiveMaximum", "maximum"}} {
+			var changed bool
+			if value, changed = toBooleanExclusiveBound(value, bound[0], bound[1]); changed {
+				amended = true
+			}
+		}
+		var changed bool
+		if value, changed = toSchemaItems(value); changed {
+			amended = true
+		}
+		if value, changed = toSingleValuedEnum(value, warnings); changed {
+			amended = true
+		}
+		return value, amended
+	case []interface{}:
+		for i, item := range value {
+			var changed bool
+			if value[i], changed = toSwaggerKeywords(item, warnings); changed {
+				amended = true
+			}
+		}
+		return value, amended
 	}
-	return tmpFile.Name()
+	return element, false
 }
 
-// AddXOrderOnDefaultExample amends the spec to specify the map value order in "default" & "example" fields as they appear
-// in the spec (supports yaml documents only).
+func toBooleanExclusiveBound(fields yaml.MapSlice, exclusive, inclusive string) (yaml.MapSlice, bool) {
+	exclusiveIndex, inclusiveIndex := -1, -1
+	for i, field := range fields {
+		switch field.Key {
+		case exclusive:
+			exclusiveIndex = i
+		case inclusive:
+			inclusiveIndex = i
+		}
+	}
+	if exclusiveIndex == -1 {
+		return fields, false
+	}
+	bound, ok := numericValue(fields[exclusiveIndex].Value)
+	if !ok {
+		// already the boolean form
+		return fields, false
+	}
+	if inclusiveIndex == -1 {
+		fields[exclusiveIndex] = yaml.MapItem{Key: inclusive, Value: fields[exclusiveIndex].Value}
+		return append(fields, yaml.MapItem{Key: exclusive, Value: true}), true
+	}
+	current, ok := numericValue(fields[inclusiveIndex].Value)
+	if ok && ((inclusive == "minimum" && current > bound) || (inclusive == "maximum" && current < bound)) {
+		// the inclusive bound is the stricter one: the exclusive bound is dropped
+		return append(fields[:exclusiveIndex], fields[exclusiveIndex+1:]...), true
+	}
+	fields[inclusiveIndex].Value = fields[exclusiveIndex].Value
+	fields[exclusiveIndex].Value = true
+	return fields, true
+}
+
+func toSchemaItems(fields yaml.MapSlice) (yaml.MapSlice, bool) {
+	for i, field := range fields {
+		if field.Key != "items" {
+			continue
+		}
+		allowed, ok := field.Value.(bool)
+		if !ok {
+			return fields, false
+		}
+		fields[i].Value = yaml.MapSlice{}
+		if allowed {
+			return fields, true
+		}
+		for j, field := range fields {
+			if field.Key == "maxItems" {
+				fields[j].Value = 0
+				return fields, true
+			}
+		}
+		return append(fields, yaml.MapItem{Key: "maxItems", Value: 0}), true
+	}
+	return fields, false
+}
+
+// toSingleValuedEnum turns a const into a single-valued enum. Only the scalar and array constants are turned,
+// so that a property named "const", whose schema is an object, is left as it is.
+func toSingleValuedEnum(fields yaml.MapSlice, warnings *warningRecorder) (yaml.MapSlice, bool) {
+	constIndex, enumIndex, defaultIndex := -1, -1, -1
+	for i, field := range fields {
+		switch field.Key {
+		case "const":
+			constIndex = i
+		case "enum":
+			enumIndex = i
+		case "default":
+			defaultIndex = i
+		}
+	}
+	if constIndex == -1 {
+		return fields, false
+	}
+	value := fields[constIndex].Value
+	if _, ok := value.(yaml.MapSlice); ok {
+		return fields, false
+	}
+	lang := DefaultLanguageFunc()
+	if defaultIndex == -1 {
+		fields = append(fields, yaml.MapItem{Key: "default", Value: value})
+	} else if lang.ToKclValue(fields[defaultIndex].Value) != lang.ToKclValue(value) {
+		warnings.warn("", "the default %s is not the const %s, the default is replaced by the const",
+			lang.ToKclValue(fields[defaultIndex].Value), lang.ToKclValue(value))
+		fields[defaultIndex].Value = value
+	}
+	if enumIndex == -1 {
+		fields[constIndex] = yaml.MapItem{Key: "enum", Value: []interface{}{value}}
+		return fields, true
+	}
+	enum, _ := fields[enumIndex].Value.([]interface{})
+	found := false
+	for _, e := range enum {
+		if lang.ToKclValue(e) == lang.ToKclValue(value) {
+			found = true
+		}
+	}
+	if !found {
+		warnings.warn("", "the const %s is not one of the enum values %s, the enum is replaced by the const",
+			lang.ToKclValue(value), lang.ToKclValue(enum))
+	}
+	fields[enumIndex].Value = []interface{}{value}
+	return append(fields[:constIndex], fields[constIndex+1:]...), true
+}
+
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// AddXOrderOnDefaultExample amends the spec to specify the map value order in "default", "example" & "x-examples" fields
+// as they appear in the spec (supports yaml documents only).
 func AddXOrderOnDefaultExample(yamlDoc interface{}) interface{} {
 	lookForSlice := func(ele interface{}, key string) (interface{}, bool) {
 		if slice, ok := ele.(yaml.MapSlice); ok {
@@ -259,6 +563,9 @@ func AddXOrderOnDefaultExample(yamlDoc interface{}) interface{} {
 		if exampleValue, ok := lookForSlice(element, "example"); ok {
 			addXOrder2MapValue(exampleValue)
 		}
+		if examplesValue, ok := lookForSlice(element, xExamples); ok {
+			addXOrder2MapValue(examplesValue)
+		}
 		// Look for the properties and add addXOrder on each property
 		if props, ok := lookForMapSlice(element, "properties"); ok {
 			for _, prop := range props {
@@ -278,36 +585,37 @@ func AddXOrderOnDefaultExample(yamlDoc interface{}) interface{} {
 }
 
 // AddXOrderOnProperty amends the spec to specify property order as they appear
-// in the spec (supports yaml documents only).
+// in the spec (supports yaml documents only). The properties of the nested schemas, such as the ones
+// of the array items, of the map values and of the allOf branches, are ordered as well.
 func AddXOrderOnProperty(yamlDoc interface{}) interface{} {
 	var addXOrder func(interface{})
 	addXOrder = func(element interface{}) {
-		if props, ok := lookForMapSlice(element, "properties"); ok {
-			for i, prop := range props {
-				if pSlice, ok := prop.Value.(yaml.MapSlice); ok {
-					isObject := false
-					xOrderIndex := -1 //Find if x-order already exists
-
-					for i, v := range pSlice {
-						if v.Key == "type" && v.Value == object {
-							isObject = true
-						}
-						if v.Key == xOrder {
-							xOrderIndex = i
-							break
-						}
+		schema, ok := element.(yaml.MapSlice)
+		if !ok {
+			return
+		}
+		for _, item := range schema {
+			switch item.Key {
+			case "properties":
+				props, _ := item.Value.(yaml.MapSlice)
+				for i, prop := range props {
+					if pSlice, ok := prop.Value.(yaml.MapSlice); ok {
+						props[i].Value = setXOrder(pSlice, i)
+						addXOrder(props[i].Value)
 					}
-
-					if xOrderIndex > -1 { //Override existing x-order
-						pSlice[xOrderIndex] = yaml.MapItem{Key: xOrder, Value: i}
-					} else { // append new x-order
-						pSlice = append(pSlice, yaml.MapItem{Key: xOrder, Value: i})
+				}
+			case "items", "additionalProperties", "additionalItems", "not":
+				if schemas, ok := item.Value.([]interface{}); ok {
+					for _, sch := range schemas {
+						addXOrder(sch)
 					}
-					prop.Value = pSlice
-					props[i] = prop
-
-					if isObject {
-						addXOrder(pSlice)
+				} else {
+					addXOrder(item.Value)
+				}
+			case "allOf", "anyOf", "oneOf":
+				if schemas, ok := item.Value.([]interface{}); ok {
+					for _, sch := range schemas {
+						addXOrder(sch)
 					}
 				}
 			}
@@ -322,6 +630,17 @@ func AddXOrderOnProperty(yamlDoc interface{}) interface{} {
 	return yamlDoc
 }
 
+// setXOrder sets the x-order of a property, overriding the existing one
+func setXOrder(prop yaml.MapSlice, order int) yaml.MapSlice {
+	for i, v := range prop {
+		if v.Key == xOrder {
+			prop[i].Value = order
+			return prop
+		}
+	}
+	return append(prop, yaml.MapItem{Key: xOrder, Value: order})
+}
+
 func lookForMapSlice(ele interface{}, key string) (yaml.MapSlice, bool) {
 	if slice, ok := ele.(yaml.MapSlice); ok {
 		for _, v := range slice {
@@ -334,3 +653,524 @@ func lookForMapSlice(ele interface{}, key string) (yaml.MapSlice, bool) {
 	}
 	return nil, false
 }
+
+// restoreXOrder restores the x-order of the properties which the flattening of the spec rewrote into a bare $ref,
+// such as the ones referring to an external definition, which drops their extensions, so that they would
+// otherwise be generated after the other properties.
+func restoreXOrder(sw *spec.Swagger, specPath string) error {
+	yamlDoc, err := swag.YAMLData(specPath)
+	if err != nil {
+		return err
+	}
+	defs, ok := lookForMapSlice(yamlDoc, "definitions")
+	if !ok {
+		return nil
+	}
+	for _, def := range defs {
+		name, _ := def.Key.(string)
+		if sch, ok := sw.Definitions[name]; ok {
+			restoreSchemaXOrder(&sch, def.Value)
+			sw.Definitions[name] = sch
+		}
+	}
+	return nil
+}
+
+func restoreSchemaXOrder(sch *spec.Schema, node interface{}) {
+	fields, ok := node.(yaml.MapSlice)
+	if !ok {
+		return
+	}
+	restoreList := func(schemas []spec.Schema, node interface{}) {
+		nodes, ok := node.([]interface{})
+		if !ok || len(nodes) != len(schemas) {
+			return
+		}
+		for i := range schemas {
+			restoreSchemaXOrder(&schemas[i], nodes[i])
+		}
+	}
+	for _, field := range fields {
+		switch field.Key {
+		case "properties":
+			props, _ := field.Value.(yaml.MapSlice)
+			for _, prop := range props {
+				name, _ := prop.Key.(string)
+				p, ok := sch.Properties[name]
+				if !ok {
+					continue
+				}
+				if _, ok := p.Extensions[xOrder]; !ok {
+					if order, ok := xOrderOf(prop.Value); ok {
+						p.AddExtension(xOrder, order)
+					}
+				}
+				restoreSchemaXOrder(&p, prop.Value)
+				sch.Properties[name] = p
+			}
+		case "items":
+			if sch.Items != nil && sch.Items.Schema != nil {
+				restoreSchemaXOrder(sch.Items.Schema, field.Value)
+			} else if sch.Items != nil {
+				restoreList(sch.Items.Schemas, field.Value)
+			}
+		case "additionalProperties":
+			if sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil {
+				restoreSchemaXOrder(sch.AdditionalProperties.Schema, field.Value)
+			}
+		case "allOf":
+			restoreList(sch.AllOf, field.Value)
+		case "oneOf":
+			restoreList(sch.OneOf, field.Value)
+		case "anyOf":
+			restoreList(sch.AnyOf, field.Value)
+		}
+	}
+}
+
+// xOrderOf returns the x-order of a property of the spec file, as the float64 value the spec decoding yields
+func xOrderOf(node interface{}) (float64, bool) {
+	fields, _ := node.(yaml.MapSlice)
+	for _, field := range fields {
+		if field.Key == xOrder {
+			if order, ok := field.Value.(int); ok {
+				return float64(order), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// preserveIntegers restores the exact value of the integer literals found in the "enum",
+// "default" and "example" fields of the spec definitions. The spec is decoded from JSON, so that
+// these literals end up as float64 values which can't represent integers beyond 2^53, whereas
+// the YAML decoding of the spec file keeps them as integers.
+//
+// Only the definitions are covered: the models are generated from them alone, and the minimal
+// flattening leaves the inline schemas of the parameters, the responses and the paths in place,
+// so that their values never reach the generated code.
+func preserveIntegers(sw *spec.Swagger, specPath string) error {
+	yamlDoc, err := swag.YAMLData(specPath)
+	if err != nil {
+		return err
+	}
+	defs, ok := lookForMapSlice(yamlDoc, "definitions")
+	if !ok {
+		return nil
+	}
+	for _, def := range defs {
+		name, _ := def.Key.(string)
+		if sch, ok := sw.Definitions[name]; ok {
+			preserveSchemaIntegers(&sch, def.Value)
+			sw.Definitions[name] = sch
+		}
+	}
+	return nil
+}
+
+func preserveSchemaIntegers(sch *spec.Schema, node interface{}) {
+	fields, ok := node.(yaml.MapSlice)
+	if !ok {
+		return
+	}
+	preserveList := func(schemas []spec.Schema, node interface{}) {
+		nodes, ok := node.([]interface{})
+		if !ok || len(nodes) != len(schemas) {
+			return
+		}
+		for i := range schemas {
+			preserveSchemaIntegers(&schemas[i], nodes[i])
+		}
+	}
+	for _, field := range fields {
+		switch field.Key {
+		case "enum":
+			values, ok := field.Value.([]interface{})
+			if !ok || len(values) != len(sch.Enum) {
+				continue
+			}
+			for i, v := range values {
+				sch.Enum[i] = exactInteger(sch.Enum[i], v)
+			}
+		case "default":
+			sch.Default = exactInteger(sch.Default, field.Value)
+		case "example":
+			sch.Example = exactInteger(sch.Example, field.Value)
+		case "properties":
+			props, _ := field.Value.(yaml.MapSlice)
+			for _, prop := range props {
+				name, _ := prop.Key.(string)
+				if p, ok := sch.Properties[name]; ok {
+					preserveSchemaIntegers(&p, prop.Value)
+					sch.Properties[name] = p
+				}
+			}
+		case "items":
+			if sch.Items != nil && sch.Items.Schema != nil {
+				preserveSchemaIntegers(sch.Items.Schema, field.Value)
+			} else if sch.Items != nil {
+				preserveList(sch.Items.Schemas, field.Value)
+			}
+		case "additionalProperties":
+			if sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil {
+				preserveSchemaIntegers(sch.AdditionalProperties.Schema, field.Value)
+			}
+		case "allOf":
+			preserveList(sch.AllOf, field.Value)
+		case "oneOf":
+			preserveList(sch.OneOf, field.Value)
+		case "anyOf":
+			preserveList(sch.AnyOf, field.Value)
+		}
+	}
+}
+
+// exactInteger returns the integer literal of the spec file in place of its float64 decoded value
+func exactInteger(decoded, literal interface{}) interface{} {
+	if _, ok := decoded.(float64); !ok {
+		return decoded
+	}
+	switch v := literal.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case uint64:
+		return v
+	}
+	return decoded
+}
+
+// propertyNamesEnumLimit is the maximum number of keys enumerated by "propertyNames" for
+// which a map gets rendered as a schema with one attribute per key. Beyond that limit,
+// the map is kept and its keys are checked against the enum.
+const propertyNamesEnumLimit = 10
+
+// walkSchemas calls fn on every schema of the spec definitions, nested schemas included.
+func walkSchemas(sw *spec.Swagger, fn func(*spec.Schema)) {
+	for k, v := range sw.Definitions {
+		walkSchema(&v, fn)
+		sw.Definitions[k] = v
+	}
+}
+
+// walkSchema calls fn on a schema and on its nested schemas.
+func walkSchema(sch *spec.Schema, fn func(*spec.Schema)) {
+	walkMap := func(m map[string]spec.Schema) {
+		for k, v := range m {
+			walkSchema(&v, fn)
+			m[k] = v
+		}
+	}
+	walkList := func(l []spec.Schema) {
+		for i := range l {
+			walkSchema(&l[i], fn)
+		}
+	}
+	fn(sch)
+	walkMap(sch.Properties)
+	walkList(sch.AllOf)
+	walkList(sch.OneOf)
+	walkList(sch.AnyOf)
+	if sch.Items != nil {
+		if sch.Items.Schema != nil {
+			walkSchema(sch.Items.Schema, fn)
+		}
+		walkList(sch.Items.Schemas)
+	}
+	if sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil {
+		walkSchema(sch.AdditionalProperties.Schema, fn)
+	}
+	if sch.AdditionalItems != nil && sch.AdditionalItems.Schema != nil {
+		walkSchema(sch.AdditionalItems.Schema, fn)
+	}
+}
+
+// propertyNamesEnum returns the string values allowed for the keys of a map by a
+// "propertyNames" constraint.
+func propertyNamesEnum(sch *spec.Schema) ([]string, bool) {
+	pn, ok := sch.ExtraProps["propertyNames"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	enum, ok := pn["enum"].([]interface{})
+	if !ok || len(enum) == 0 {
+		return nil, false
+	}
+	keys := make([]string, 0, len(enum))
+	for _, e := range enum {
+		key, ok := e.(string)
+		if !ok {
+			return nil, false
+		}
+		keys = append(keys, key)
+	}
+	return keys, true
+}
+
+// propertyNamesValidations returns the string validations of the keys of a map set by a
+// "propertyNames" constraint, i.e. its pattern, minLength and maxLength.
+func propertyNamesValidations(sch *spec.Schema) (pattern string, minLength, maxLength *int64) {
+	pn, ok := sch.ExtraProps["propertyNames"].(map[string]interface{})
+	if !ok {
+		return "", nil, nil
+	}
+	pattern, _ = pn["pattern"].(string)
+	bound := func(key string) *int64 {
+		if v, ok := pn[key].(float64); ok && v >= 0 {
+			n := int64(v)
+			return &n
+		}
+		return nil
+	}
+	return pattern, nonZeroBound(bound("minLength")), bound("maxLength")
+}
+
+// hasKeyValidations tells if a "propertyNames" constraint validates the keys of a map
+func hasKeyValidations(sch *spec.Schema) bool {
+	_, hasKeyEnum := propertyNamesEnum(sch)
+	pattern, minLength, maxLength := propertyNamesValidations(sch)
+	return hasKeyEnum || pattern != "" || minLength != nil || maxLength != nil
+}
+
+// propertiesBounds returns the bounds of the number of entries of a map, as specified by "minProperties" and
+// "maxProperties". They are left out for the objects with properties, which are rendered as schemas.
+func propertiesBounds(sch *spec.Schema) (*int64, *int64) {
+	if len(sch.Properties) > 0 || (len(sch.Type) > 0 && !sch.Type.Contains(object)) {
+		return nil, nil
+	}
+	return nonZeroBound(sch.MinProperties), sch.MaxProperties
+}
+
+// isWriteOnly tells if a schema is flagged "writeOnly", which is kept as an extra property
+// since Swagger 2.0 doesn't define it.
+func isWriteOnly(sch *spec.Schema) bool {
+	writeOnly, _ := sch.ExtraProps["writeOnly"].(bool)
+	return writeOnly
+}
+
+// deprecation tells if a schema is flagged "deprecated", which is kept as an extra property since Swagger 2.0
+// doesn't define it, or by the x-deprecated extension, along with the reason set by the x-deprecated-reason extension.
+func deprecation(sch *spec.Schema) (bool, string) {
+	deprecated, _ := sch.ExtraProps["deprecated"].(bool)
+	if !deprecated {
+		deprecated, _ = sch.Extensions.GetBool(xDeprecated)
+	}
+	if !deprecated {
+		return false, ""
+	}
+	reason, _ := sch.Extensions.GetString(xDeprecatedReason)
+	return true, reason
+}
+
+// preservesUnknownFields tells if the unknown fields of an object are preserved by kubernetes,
+// as set by the x-kubernetes-preserve-unknown-fields extension.
+func preservesUnknownFields(sch *spec.Schema) bool {
+	preserves, _ := sch.Extensions.GetBool(k8sPreserveUnknownFields)
+	return preserves
+}
+
+// printerColumn returns the CRD printer column set on a property by the x-kcl-printer-column extension.
+func printerColumn(sch *spec.Schema) *GenPrinterColumn {
+	column, ok := sch.Extensions[xKclPrinterColumn].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	name, _ := column["name"].(string)
+	if name == "" {
+		return nil
+	}
+	priority, _ := column["priority"].(float64)
+	return &GenPrinterColumn{Name: name, Priority: int64(priority)}
+}
+
+// kclMixins returns the mixins of a definition set by the x-kcl-mixins extension.
+func kclMixins(sch *spec.Schema) []string {
+	var mixins []string
+	values, _ := sch.Extensions[xKclMixins].([]interface{})
+	for _, value := range values {
+		if mixin, ok := value.(string); ok {
+			mixins = append(mixins, mixin)
+		}
+	}
+	return mixins
+}
+
+// inlineArrayDefinitions replaces the references to the array definitions with the definitions
+// themselves, and drops these definitions: a list has no KCL schema, so that its checks, such as
+// minItems or uniqueItems, are rendered on the properties holding it instead. The tuples, and the array
+// definitions which refer to themselves, such as trees, are kept.
+func inlineArrayDefinitions(sw *spec.Swagger) {
+	arrays := make(map[string]string)
+	for name, def := range sw.Definitions {
+		if _, ok := def.Extensions[xKclType]; ok || len(def.Type) != 1 || !def.Type.Contains(array) {
+			continue
+		}
+		// tuples are generated as schemas
+		if def.Items != nil && len(def.Items.Schemas) > 0 {
+			continue
+		}
+		arrays["#/definitions/"+escapeJSONPointer(name)] = name
+	}
+	for ref, name := range arrays {
+		if refersTo(sw, arrays, name, ref, map[string]bool{}) {
+			delete(arrays, ref)
+		}
+	}
+	if len(arrays) == 0 {
+		return
+	}
+	walkSchemas(sw, func(sch *spec.Schema) {
+		name, ok := arrays[sch.Ref.String()]
+		if !ok {
+			return
+		}
+		debugLog("inlining the array definition %s", name)
+		var inlined spec.Schema
+		data, _ := json.Marshal(sw.Definitions[name])
+		if err := json.Unmarshal(data, &inlined); err != nil {
+			return
+		}
+		for k, v := range sch.Extensions {
+			inlined.AddExtension(k, v)
+		}
+		if sch.Description != "" {
+			inlined.Description = sch.Description
+		}
+		*sch = inlined
+	})
+	for _, name := range arrays {
+		delete(sw.Definitions, name)
+	}
+}
+
+// refersTo tells if the definition refers to the ref, directly or through the array definitions it refers to.
+func refersTo(sw *spec.Swagger, arrays map[string]string, name, ref string, visited map[string]bool) bool {
+	if visited[name] {
+		return false
+	}
+	visited[name] = true
+	found := false
+	def := sw.Definitions[name]
+	walkSchema(&def, func(sch *spec.Schema) {
+		target := sch.Ref.String()
+		if found || target == "" {
+			return
+		}
+		if target == ref {
+			found = true
+		} else if next, ok := arrays[target]; ok {
+			found = refersTo(sw, arrays, next, ref, visited)
+		}
+	})
+	return found
+}
+
+// expandPropertyNames rewrites the maps whose keys are constrained to a small enum by
+// "propertyNames" as objects with one optional property per allowed key, typed after
+// the map value schema.
+func expandPropertyNames(sw *spec.Swagger) {
+	walkSchemas(sw, func(sch *spec.Schema) {
+		keys, ok := propertyNamesEnum(sch)
+		if !ok || len(keys) > propertyNamesEnumLimit {
+			return
+		}
+		debugLog("expanding propertyNames enum %s into properties", strings.Join(keys, ", "))
+		value := spec.Schema{}
+		if sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil {
+			value = *sch.AdditionalProperties.Schema
+		}
+		if sch.Properties == nil {
+			sch.Properties = make(spec.SchemaProperties, len(keys))
+		}
+		for i, key := range keys {
+			if _, exists := sch.Properties[key]; exists {
+				continue
+			}
+			prop := value
+			prop.Extensions = make(spec.Extensions, len(value.Extensions)+1)
+			for k, v := range value.Extensions {
+				prop.Extensions[k] = v
+			}
+			prop.Extensions[xOrder] = float64(i)
+			sch.Properties[key] = prop
+		}
+		if len(sch.Type) == 0 {
+			sch.Typed(object, "")
+		}
+		sch.AdditionalProperties = nil
+		delete(sch.ExtraProps, "propertyNames")
+	})
+}
+
+// nameBundledDefinitions names the definitions bundled by the flattening, such as the ones of the other spec
+// files, through the x-kcl-name extension, unless they already set it. The flattening names them after the last
+// token of their $ref turned into a JSON name, e.g. "address" for "common.yaml#/Address": they are named after
+// the token turned into an identifier instead, unless it is the name of another definition.
+func nameBundledDefinitions(sw *spec.Swagger, local map[string]bool) {
+	// the names are compared regardless of the case, like the names of the files the models are generated into
+	taken := make(map[string]int, len(sw.Definitions))
+	for name, sch := range sw.Definitions {
+		taken[strings.ToLower(kclName(&sch, name))]++
+	}
+	for _, name := range sortedDefinitionNames(sw) {
+		if local[name] {
+			continue
+		}
+		sch := sw.Definitions[name]
+		if _, ok := sch.Extensions[xKclName]; ok {
+			continue
+		}
+		if _, ok := sch.Extensions[xKclType]; ok {
+			continue
+		}
+		named := swag.ToGoName(name)
+		if named == "" || named == name {
+			continue
+		}
+		if count := taken[strings.ToLower(named)]; count > 1 || count == 1 && !strings.EqualFold(named, name) {
+			continue
+		}
+		debugLog("naming the bundled definition %s: %s", name, named)
+		sch.AddExtension(xKclName, named)
+		sw.Definitions[name] = sch
+	}
+}
+
+// nameFromTitles names the definitions having a title after it, through the x-kcl-name extension, unless they
+// already set it. The name is the title turned into an identifier, followed by a number when it collides with
+// the name of another definition.
+func nameFromTitles(sw *spec.Swagger, warnings *warningRecorder) {
+	taken := make(map[string]bool, len(sw.Definitions))
+	for name, sch := range sw.Definitions {
+		taken[kclName(&sch, name)] = true
+	}
+	for _, name := range sortedDefinitionNames(sw) {
+		sch := sw.Definitions[name]
+		if sch.Title == "" {
+			continue
+		}
+		if _, ok := sch.Extensions[xKclName]; ok {
+			continue
+		}
+		if _, ok := sch.Extensions[xKclType]; ok {
+			continue
+		}
+		base := swag.ToGoName(sch.Title)
+		if base == "" || base == name {
+			continue
+		}
+		titled := base
+		for i := 2; taken[titled]; i++ {
+			titled = base + strconv.Itoa(i)
+		}
+		if titled != base {
+			warnings.warn(name, "the title %q of %s collides with the name of another definition, it is named %s", sch.Title, name, titled)
+		}
+		debugLog("naming %s after its title %q: %s", name, sch.Title, titled)
+		delete(taken, name)
+		taken[titled] = true
+		sch.AddExtension(xKclName, titled)
+		sw.Definitions[name] = sch
+	}
+}
@@ -67,3 +67,36 @@ func readFileContent(t *testing.T, p string) (content string) {
 	}
 	return string(data)
 }
+
+func TestWithXOrderKeepsSpecOrder(t *testing.T) {
+	names := []string{"zeta", "alpha", "mu", "beta", "omega", "gamma", "kappa", "delta", "xi", "epsilon", "nu", "eta"}
+	var content strings.Builder
+	content.WriteString("swagger: \"2.0\"\ninfo:\n  title: kcl\n  version: v0.0.1\npaths: {}\ndefinitions:\n  Greek:\n    type: object\n    properties:\n")
+	for _, name := range names {
+		content.WriteString(fmt.Sprintf("      %s:\n        type: string\n", name))
+	}
+	specPath := filepath.Join(t.TempDir(), "spec.yaml")
+	if err := os.WriteFile(specPath, []byte(content.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var document yaml.MapSlice
+	ordered, err := WithXOrder(specPath, AddXOrderOnProperty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(readFileContent(t, ordered)), &document); err != nil {
+		t.Fatal(err)
+	}
+	defs, _ := lookForMapSlice(document, "definitions")
+	props, _ := lookForMapSlice(defs[0].Value, "properties")
+	if !assert.Len(t, props, len(names)) {
+		return
+	}
+	for i, prop := range props {
+		assert.Equal(t, names[i], prop.Key)
+		order, ok := xOrderOf(prop.Value)
+		assert.True(t, ok)
+		assert.Equal(t, float64(i), order)
+	}
+}
@@ -16,7 +16,6 @@ package generator
 
 import (
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/go-openapi/spec"
@@ -43,6 +42,9 @@ type GenDefinition struct {
 	DependsOn            []string
 	External             bool
 	HasPatternValidation bool
+	// ExampleInstance is the example of the definition rendered as an instance of its schema,
+	// only set when the examples are generated
+	ExampleInstance string
 }
 
 // GenDefinitions represents a list of operations to generate
@@ -64,32 +66,34 @@ type GenSchemaList []GenSchema
 type GenSchema struct {
 	resolvedType
 	sharedValidations
-	Example                    interface{}
-	OriginalName               string
-	Name                       string
-	EscapedName                string
-	Suffix                     string
-	Path                       string
-	ValueExpression            string
-	IndexVar                   string
-	KeyVar                     string
-	Title                      string
-	Description                string
-	ReceiverName               string
-	Items                      *GenSchema
-	AllowsAdditionalItems      bool
-	HasAdditionalItems         bool
-	AdditionalItems            *GenSchema
-	Object                     *GenSchema
-	XMLName                    string
-	CustomTag                  string
-	Properties                 GenSchemaList
-	AllOf                      GenSchemaList
+	Example               interface{}
+	OriginalName          string
+	Name                  string
+	EscapedName           string
+	Suffix                string
+	Path                  string
+	Title                 string
+	Description           string
+	Items                 *GenSchema
+	AllowsAdditionalItems bool
+	HasAdditionalItems    bool
+	AdditionalItems       *GenSchema
+	Object                *GenSchema
+	XMLName               string
+	CustomTag             string
+	Properties            GenSchemaList
+	AllOf                 GenSchemaList
+	// OneOf lists the types of the union a oneOf schema is rendered as, e.g. Cat | Dog
+	OneOf GenSchemaList
+	// AnyOf lists the types of the union an anyOf schema is rendered as, which doesn't tell that
+	// the value may match several types
+	AnyOf                      GenSchemaList
 	HasAdditionalProperties    bool
 	IsAdditionalProperties     bool
 	AdditionalProperties       *GenSchema
 	StrictAdditionalProperties bool
 	ReadOnly                   bool
+	WriteOnly                  bool
 	IsBaseType                 bool
 	HasBaseType                bool
 	IsSubType                  bool
@@ -100,6 +104,46 @@ type GenSchema struct {
 	Parents                    []string
 	Default                    interface{}
 	ExternalDocs               *spec.ExternalDocumentation
+	// Examples are the named examples of the schema, rendered instead of its single example
+	Examples []GenExample
+	// SourcePath is the JSON pointer of the schema in the original spec,
+	// only set when source comments are enabled
+	SourcePath string
+	// Renamed tells the attribute name of the property is disambiguated from another property mangled to
+	// the same name, Name being the original name of the property
+	Renamed bool
+	// PrinterColumn is the CRD printer column which displays the property, if any
+	PrinterColumn *GenPrinterColumn
+	// Mixins are the names of the mixins the schema mixes in
+	Mixins []string
+	// Rules are the CEL validation rules of the schema, set by the x-kubernetes-validations extension
+	Rules []GenRule
+	// Deprecated tells the schema or the property is deprecated, and DeprecatedReason why, if known
+	Deprecated       bool
+	DeprecatedReason string
+	// RequireOptionalChecks tells if the checks of an optional property also forbid it to be None
+	RequireOptionalChecks bool
+	// SkipChecks tells if the checks of the property are left out, since its quoted name can't be referenced
+	SkipChecks bool
+}
+
+// GuardsNone tells if the checks of the schema are skipped when its value is None
+func (g GenSchema) GuardsNone() bool {
+	return !g.Required && !g.RequireOptionalChecks
+}
+
+// Union returns the types of the union a oneOf or an anyOf schema is rendered as, if any
+func (g GenSchema) Union() GenSchemaList {
+	if len(g.OneOf) > 0 {
+		return g.OneOf
+	}
+	return g.AnyOf
+}
+
+// GenPrinterColumn describes a CRD printer column, i.e. a property displayed by "kubectl get"
+type GenPrinterColumn struct {
+	Name     string
+	Priority int64
 }
 
 func (g GenSchemaList) Len() int      { return len(g) }
@@ -145,54 +189,74 @@ type sharedValidations struct {
 
 	Enum      []interface{}
 	ItemsEnum []interface{}
+	// EnumAllowsNone is set when null is a member of the enum, which is left out of the literal union:
+	// the value is then optional, so that it may be None
+	EnumAllowsNone bool
+	// KeyEnum lists the allowed keys of a map, as specified by "propertyNames"
+	KeyEnum []interface{}
+	// KeyPattern, KeyMinLength and KeyMaxLength validate the keys of a map, as specified by "propertyNames"
+	KeyPattern   string
+	KeyMinLength *int64
+	KeyMaxLength *int64
 
 	// Slice validations
 	MinItems            *int64
 	MaxItems            *int64
 	UniqueItems         bool
 	HasSliceValidations bool
+	// TupleLength is the exact length of a tuple which disallows additional items
+	TupleLength *int64
+	// PinnedValue is the KCL literal of the only value allowed by a single-value boolean enum,
+	// or by a read-only property with a default
+	PinnedValue string
 
-	// Not used yet (perhaps intended for maxProperties, minProperties validations?)
-	NeedsSize bool
+	// Map validations, the bounds of the number of entries of a map
+	MinProperties *int64
+	MaxProperties *int64
 
 	// NOTE: "patternProperties" and "dependencies" not supported by Swagger 2.0
 }
 
-// pruneEnums omit nil from enum values
-func (s *sharedValidations) pruneEnums(sg schemaGenContext) {
+// pruneEnums omit nil from enum values, it fails on the complex values which can't be enum values in KCL
+func (s *sharedValidations) pruneEnums(sg schemaGenContext) error {
 	if s.Enum == nil {
-		return
+		return nil
 	}
 
 	var newEnums []interface{}
 	containsNil := false
-	containsComplex := false
+	var complexValue interface{}
 	for _, enumValue := range s.Enum {
 		if enumValue != nil {
 			switch enumValue.(type) {
 			// bool, string, number(int, float)
-			case bool, string, int, float64, float32:
+			case bool, string, int, int64, uint64, float64, float32:
 				newEnums = append(newEnums, enumValue)
 			default:
-				containsComplex = true
+				if complexValue == nil {
+					complexValue = enumValue
+				}
 			}
 		} else {
 			containsNil = true
 		}
 	}
-	if containsComplex || containsNil {
+	if complexValue != nil || containsNil {
 		modelName := sg.Path
 		if sg.Container != "" {
 			modelName = fmt.Sprintf("%s.%s", sg.Container, modelName)
 		}
 		if containsNil {
 			s.Enum = newEnums
-			log.Printf("[WARN] enum values in model <%s> contains nil value and the nil value is omitted by KCL", modelName)
+			s.EnumAllowsNone = true
+			debugLog("enum values in model <%s> contains nil value, the value is optional", modelName)
 		}
-		if containsComplex {
-			log.Fatalf("enum values in model <%s> contains complex value type which is forbidden in KCL", modelName)
+		if complexValue != nil {
+			return fmt.Errorf("enum values in model <%s> contains complex value type which is forbidden in KCL: %s",
+				modelName, DefaultLanguageFunc().ToKclValue(complexValue))
 		}
 	}
+	return nil
 }
 
 // GenApp represents all the meta data needed to generate an application
@@ -251,6 +315,12 @@ type GenSerGroup struct {
 	AllSerializers GenSerializers
 }
 
+// GenExample is a named example of a schema
+type GenExample struct {
+	Name  string
+	Value interface{}
+}
+
 // GenSerializers sorted representation of serializers
 type GenSerializers []GenSerializer
 
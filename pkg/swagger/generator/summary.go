@@ -0,0 +1,178 @@
+package generator
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Severity tells how a warning raised during the generation affects the generated models
+type Severity string
+
+const (
+	// SeverityWarning is the severity of the warnings about the spec, whose models are generated anyway
+	SeverityWarning Severity = "warning"
+	// SeverityUnsupported is the severity of the constructs of the spec which are not supported,
+	// whose models are generated differently from what the spec describes
+	SeverityUnsupported Severity = "unsupported"
+)
+
+// Warning is a warning raised during the generation
+type Warning struct {
+	Severity Severity
+	// Model is the name of the definition the warning is raised for, empty when it doesn't concern a single one
+	Model   string
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Model == "" {
+		return fmt.Sprintf("%s: %s", w.Severity, w.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", w.Severity, w.Model, w.Message)
+}
+
+// warningRecorder records the warnings raised during a generation, each generation recording its own ones in
+// the recorder of its GenOpts, see Generate. A nil recorder only logs the warnings.
+type warningRecorder struct {
+	// mu guards the warnings, raised concurrently while rendering
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+// warn logs a warning raised during the generation of the model, and records it
+func (r *warningRecorder) warn(model string, frmt string, args ...interface{}) {
+	r.raise(Warning{Severity: SeverityWarning, Model: model, Message: fmt.Sprintf(frmt, args...)})
+}
+
+// unsupported warns about a construct of the spec which can't be generated as is
+func (r *warningRecorder) unsupported(model string, frmt string, args ...interface{}) {
+	r.raise(Warning{Severity: SeverityUnsupported, Model: model, Message: fmt.Sprintf(frmt, args...)})
+}
+
+// raise logs a warning and records it, unless it is already recorded: the same schema may be resolved several times
+func (r *warningRecorder) raise(warning Warning) {
+	log.Printf("[WARN] %s", warning.Message)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, w := range r.warnings {
+		if w == warning {
+			return
+		}
+	}
+	r.warnings = append(r.warnings, warning)
+}
+
+// recorded returns the warnings recorded so far
+func (r *warningRecorder) recorded() []Warning {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Warning(nil), r.warnings...)
+}
+
+// checkStrict fails in strict mode when warnings have been raised during the generation
+func (g *GenOpts) checkStrict() error {
+	if !g.Strict {
+		return nil
+	}
+	warnings := g.warnings.recorded()
+	switch len(warnings) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("the generation raised a warning in strict mode: %s", warnings[0])
+	default:
+		return fmt.Errorf("the generation raised %d warnings in strict mode, the first one is %s", len(warnings), warnings[0])
+	}
+}
+
+// GenSummary reports the results of a generation
+type GenSummary struct {
+	Definitions int
+	Files       int
+	Checks      int
+	Warnings    int
+	Unsupported int
+
+	// mu guards the counts of the files, which are written concurrently
+	mu sync.Mutex
+}
+
+// addFile counts a written file along with the checks it contains
+func (s *GenSummary) addFile(checks int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Files++
+	s.Checks += checks
+}
+
+// done counts the warnings recorded during the generation
+func (s *GenSummary) done(warnings []Warning) {
+	s.Warnings = len(warnings)
+	s.Unsupported = 0
+	for _, w := range warnings {
+		if w.Severity == SeverityUnsupported {
+			s.Unsupported++
+		}
+	}
+}
+
+func (s *GenSummary) String() string {
+	var b strings.Builder
+	b.WriteString("generation summary:\n")
+	fmt.Fprintf(&b, "  definitions generated:  %d\n", s.Definitions)
+	fmt.Fprintf(&b, "  files written:          %d\n", s.Files)
+	fmt.Fprintf(&b, "  checks emitted:         %d\n", s.Checks)
+	fmt.Fprintf(&b, "  warnings raised:        %d\n", s.Warnings)
+	fmt.Fprintf(&b, "  unsupported constructs: %d\n", s.Unsupported)
+	return b.String()
+}
+
+// reportSummary prints the summary and/or writes it to the summary file, as required by the options
+func (g *GenOpts) reportSummary() error {
+	if g.summary == nil {
+		return nil
+	}
+	g.summary.done(g.warnings.recorded())
+	if g.Summary {
+		fmt.Print(g.summary.String())
+	}
+	if g.SummaryFile != "" {
+		if err := os.WriteFile(g.SummaryFile, []byte(g.summary.String()), 0644); err != nil {
+			return fmt.Errorf("failed to write the summary file %q: %v", g.SummaryFile, err)
+		}
+	}
+	return nil
+}
+
+// countChecks counts the check expressions of the check blocks in a generated file
+func countChecks(content []byte) int {
+	count := 0
+	checkIndent := -1
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if checkIndent >= 0 {
+			if indent > checkIndent {
+				count++
+				continue
+			}
+			checkIndent = -1
+		}
+		if trimmed == "check:" {
+			checkIndent = indent
+		}
+	}
+	return count
+}
@@ -15,41 +15,75 @@
 package generator
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"runtime"
 	"sort"
+	"sync"
 
 	"github.com/go-openapi/analysis"
 	"github.com/go-openapi/loads"
 	"github.com/go-openapi/spec"
 )
 
+// Generate generates the models of the spec. The warnings raised during the generation are logged
+// and returned in opts.Warnings, even when the generation fails.
 func Generate(opts *GenOpts) error {
-	generator, err := newGenerator(opts)
+	return generate(context.Background(), opts)
+}
+
+// generate generates the models, the generation stopping with the error of the context when it is done
+func generate(ctx context.Context, opts *GenOpts) error {
+	opts.warnings = new(warningRecorder)
+	defer func() {
+		opts.Warnings = opts.warnings.recorded()
+		opts.removeAmendedSpecs()
+	}()
+	if opts.Summary || opts.SummaryFile != "" {
+		opts.summary = new(GenSummary)
+	}
+	generator, err := newGenerator(ctx, opts)
 	if err != nil {
 		return err
 	}
-	return generator.Generate()
+	if err := generator.Generate(); err != nil {
+		return err
+	}
+	return opts.reportSummary()
 }
 
-func newGenerator(opts *GenOpts) (*generator, error) {
+func newGenerator(ctx context.Context, opts *GenOpts) (*generator, error) {
 	if err := opts.CheckOpts(); err != nil {
 		return nil, err
 	}
+	opts.LanguageOpts.warnings = opts.warnings
 
-	opts.setTemplates()
+	if err := opts.setTemplates(); err != nil {
+		return nil, err
+	}
 
 	specDoc, analyzed, err := opts.analyzeSpec()
 	if err != nil {
 		return nil, err
 	}
 
-	models, err := gatherModels(specDoc)
+	models, err := gatherModels(specDoc, opts.ModelNames)
 	if err != nil {
 		return nil, err
 	}
+	if len(models) == 0 {
+		if sw := specDoc.Spec(); sw.Paths != nil && len(sw.Paths.Paths) > 0 {
+			opts.warnings.warn("", "the spec %s has no definitions but only paths, no model is generated: the operations are not supported, declare the schemas to generate under definitions", opts.Spec)
+		} else {
+			opts.warnings.warn("", "the spec %s has no definitions, no model is generated", opts.Spec)
+		}
+	}
 
 	return &generator{
+		ctx:           ctx,
 		SpecDoc:       specDoc,
 		Analyzed:      analyzed,
 		Models:        models,
@@ -60,6 +94,7 @@ func newGenerator(opts *GenOpts) (*generator, error) {
 }
 
 type generator struct {
+	ctx           context.Context
 	Name          string
 	SpecDoc       *loads.Document
 	Analyzed      *analysis.Spec
@@ -76,14 +111,82 @@ func (a *generator) Generate() error {
 	if err != nil {
 		return err
 	}
+	if err := a.GenOpts.checkStrict(); err != nil {
+		return err
+	}
 
-	// NOTE: relative to previous implem with chan.
-	// IPC removed concurrent execution because of the FuncMap that is being shared
-	// templates are now lazy loaded so there is concurrent map access I can't guard
+	if a.GenOpts.Clean {
+		if err := a.cleanModelsPackage(); err != nil {
+			return err
+		}
+	}
 
 	log.Printf("rendering %d models", len(app.Models))
-	for _, mod := range app.Models {
-		if err := a.GenOpts.renderDefinition(&mod); err != nil {
+	if a.GenOpts.summary != nil {
+		a.GenOpts.summary.Definitions = len(app.Models)
+	}
+	if a.GenOpts.SingleFile {
+		if err := a.renderModelsFiles(app.Models); err != nil {
+			return err
+		}
+	} else if err := a.renderDefinitions(app.Models); err != nil {
+		return err
+	}
+	if a.GenOpts.GVKRegistry {
+		if err := a.renderGVKRegistry(app.Models); err != nil {
+			return err
+		}
+	}
+	if a.GenOpts.GenIndex {
+		if err := a.renderIndex(app.Models); err != nil {
+			return err
+		}
+	}
+	if a.GenOpts.GenMod {
+		if err := a.renderKclMod(app.Models); err != nil {
+			return err
+		}
+	}
+	// the warnings raised while rendering, e.g. by the index, fail the generation after the models are written
+	return a.GenOpts.checkStrict()
+}
+
+// renderDefinitions renders the models with a pool of workers. Each worker renders distinct files with its own
+// clones of the templates, so that nothing but the summary is shared. The error of the first model failing to
+// render, in the order of the models, is returned.
+func (a *generator) renderDefinitions(models GenDefinitions) error {
+	workers := a.GenOpts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if a.GenOpts.DryRun {
+		// the files are printed in the order of the models
+		workers = 1
+	}
+	if workers > len(models) {
+		workers = len(models)
+	}
+	errs := make([]error, len(models))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if errs[i] = a.ctx.Err(); errs[i] == nil {
+					errs[i] = a.GenOpts.renderDefinition(&models[i])
+				}
+			}
+		}()
+	}
+	for i := range models {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
 			return err
 		}
 	}
@@ -101,8 +204,18 @@ func (a *generator) makeCodegen() (GenApp, error) {
 
 	log.Println("planning definitions")
 
+	// the models are planned in the order of their names, so that the warnings are raised in a stable order
+	names := make([]string, 0, len(a.Models))
+	for mn := range a.Models {
+		names = append(names, mn)
+	}
+	sort.Strings(names)
 	genModels := make(GenDefinitions, 0, len(a.Models))
-	for mn, m := range a.Models {
+	for _, mn := range names {
+		m := a.Models[mn]
+		if err := a.ctx.Err(); err != nil {
+			return GenApp{}, err
+		}
 		model, err := makeGenDefinition(
 			mn,
 			a.ModelsPackage,
@@ -137,3 +250,58 @@ func (a *generator) makeCodegen() (GenApp, error) {
 		GenOpts:      a.GenOpts,
 	}, nil
 }
+
+// cleanModelsPackage removes the KCL files of the models package, including its subpackages, and the directories
+// they leave empty. The other files are kept, and the target directory itself is never cleaned, since it is not
+// created by the generator.
+func (a *generator) cleanModelsPackage() error {
+	root, _, err := a.GenOpts.location(&modelsFileTemplate, &GenModelsFile{Package: a.ModelsPackage})
+	if err != nil {
+		return err
+	}
+	if !a.GenOpts.writesFiles() {
+		log.Printf("the models package %q is not cleaned, since the files are not written", root)
+		return nil
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	absTarget, err := filepath.Abs(a.GenOpts.Target)
+	if err != nil {
+		return err
+	}
+	if absRoot == absTarget {
+		return fmt.Errorf("the models package %q is the target directory, which is not cleaned: set a models package to clean", root)
+	}
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+	log.Printf("cleaning the models package %q", root)
+	var dirs []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		if filepath.Ext(path) != ".k" {
+			return nil
+		}
+		return os.Remove(path)
+	})
+	if err != nil {
+		return err
+	}
+	// the subdirectories are walked after their parent, they are removed first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := os.ReadDir(dirs[i]); err == nil && len(entries) == 0 {
+			if err := os.Remove(dirs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
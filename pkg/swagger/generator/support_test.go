@@ -1,9 +1,14 @@
 package generator
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
 	crdGen "kcl-lang.io/kcl-openapi/pkg/kube_resource/generator"
@@ -34,6 +39,13 @@ func TestGenerate_CRD2KCL(t *testing.T) {
 	utils.DoTestDirs(t, utils.KubeTestDirs, apiConvertModel, true)
 }
 
+// TestGenerate_Options generates the cases of testdata/options and testdata/crd_options, which
+// configure the generation with the options of their options.json file
+func TestGenerate_Options(t *testing.T) {
+	utils.DoTestDirs(t, []string{filepath.Join("testdata", "options")}, apiConvertModel, false)
+	utils.DoTestDirs(t, []string{filepath.Join("testdata", "crd_options")}, apiConvertModel, true)
+}
+
 func apiConvertModel(integrationGenOpts utils.IntegrationGenOpts) error {
 	opts := new(GenOpts)
 	opts.Spec = integrationGenOpts.SpecPath
@@ -41,22 +53,92 @@ func apiConvertModel(integrationGenOpts utils.IntegrationGenOpts) error {
 	opts.KeepOrder = true
 	opts.ValidateSpec = !integrationGenOpts.IsCrd
 	opts.ModelPackage = integrationGenOpts.ModelPackage
+	if err := readCaseOptions(integrationGenOpts.SpecPath, opts); err != nil {
+		return err
+	}
 
 	if err := opts.EnsureDefaults(); err != nil {
 		return fmt.Errorf("fill default options failed: %s", err.Error())
 	}
 	if integrationGenOpts.IsCrd {
-		spec, err := crdGen.GetSpec(&crdGen.GenOpts{
-			Spec: opts.Spec,
-		})
+		crdOpts := &crdGen.GenOpts{}
+		if err := readCaseOptions(integrationGenOpts.SpecPath, crdOpts); err != nil {
+			return err
+		}
+		crdOpts.Spec = opts.Spec
+		spec, err := crdGen.GetSpec(crdOpts)
 		if err != nil {
 			return fmt.Errorf("get spec from crd failed: %s", err.Error())
 		}
+		defer os.RemoveAll(filepath.Dir(spec))
 		opts.Spec = spec
 	}
 	err := Generate(opts)
 	if err != nil {
 		return fmt.Errorf("generate failed: %s", err.Error())
 	}
+	if err := checkWarnings(integrationGenOpts.SpecPath, opts.Warnings); err != nil {
+		return err
+	}
+	return checkNoValueExpressions(opts.Target)
+}
+
+// readCaseOptions sets the options of the options.json file next to the spec of a case, if any,
+// e.g. {"FormatRangeChecks": true}
+func readCaseOptions(specPath string, opts interface{}) error {
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(specPath), "options.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, opts); err != nil {
+		return fmt.Errorf("read the options of %s failed: %s", specPath, err.Error())
+	}
+	return nil
+}
+
+// checkWarnings compares the warnings raised by the generation of a case with the ones listed by the
+// warnings.txt file next to its spec, if any, one per line. An empty file expects no warning
+func checkWarnings(specPath string, warnings []Warning) error {
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(specPath), "warnings.txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	raised := make([]string, 0, len(warnings))
+	for _, w := range warnings {
+		raised = append(raised, w.String())
+	}
+	expected, got := strings.TrimSpace(string(data)), strings.Join(raised, "\n")
+	if expected != got {
+		return fmt.Errorf("unexpected warnings raised by %s, expect:\n%s\ngot:\n%s", specPath, expected, got)
+	}
 	return nil
 }
+
+// valueExpression matches the Go value expressions of go-swagger, such as m.Name or o.Items[i],
+// which must never leak into the generated KCL code
+var valueExpression = regexp.MustCompile(`(^|[^\w.])[mo]\.\w`)
+
+// checkNoValueExpressions reports the value expressions found in the files generated under dir
+func checkNoValueExpressions(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".k" {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for i, line := range strings.Split(string(content), "\n") {
+			if valueExpression.MatchString(line) {
+				return fmt.Errorf("%s:%d: unexpected value expression: %s", path, i+1, line)
+			}
+		}
+		return nil
+	})
+}
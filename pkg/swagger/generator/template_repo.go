@@ -19,8 +19,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 	"text/template/parse"
@@ -29,6 +31,7 @@ import (
 	_ "embed"
 
 	"github.com/go-openapi/inflect"
+	"github.com/go-openapi/spec"
 	"github.com/go-openapi/swag"
 	"github.com/kr/pretty"
 )
@@ -39,8 +42,6 @@ var (
 
 	// FuncMapFunc yields a map with all functions for templates
 	FuncMapFunc func(*LanguageOpts) template.FuncMap
-
-	templates *Repository
 )
 
 func initTemplateRepo() {
@@ -52,7 +53,6 @@ func initTemplateRepo() {
 
 	assets = defaultAssets()
 	protectedTemplates = defaultProtectedTemplates()
-	templates = NewRepository(FuncMapFunc(DefaultLanguageFunc()))
 }
 
 // DefaultFuncMap yields a map with default functions for use n the templates.
@@ -91,6 +91,7 @@ func DefaultFuncMap(lang *LanguageOpts) template.FuncMap {
 		"joinFilePath":   filepath.Join,
 		"comment":        padComment,
 		"doc":            padDocument,
+		"seeAlso":        seeAlso,
 		"blockcomment":   blockComment,
 		"inspect":        pretty.Sprint,
 		"cleanPath":      path.Clean,
@@ -141,8 +142,15 @@ func DefaultFuncMap(lang *LanguageOpts) template.FuncMap {
 			return properties
 		},
 		"toKCLValue":    lang.ToKclValue,
+		"toKCLPattern":  lang.ToKclPattern,
 		"escapeKeyword": lang.MangleModelName,
 		"nonEmptyValue": lang.NonEmptyValue,
+		"isZero": func(bound *int64) bool {
+			return bound != nil && *bound == 0
+		},
+		"toKCLNumber": func(bound *float64) string {
+			return strconv.FormatFloat(*bound, 'f', -1, 64)
+		},
 	}
 }
 
@@ -173,6 +181,18 @@ var introductionTmpl string
 //go:embed templates/propertydoc.gotmpl
 var propertyDocTmpl string
 
+//go:embed templates/gvkregistry.gotmpl
+var gvkRegistryTmpl string
+
+//go:embed templates/index.gotmpl
+var indexTmpl string
+
+//go:embed templates/kclmod.gotmpl
+var kclModTmpl string
+
+//go:embed templates/modelsfile.gotmpl
+var modelsFileTmpl string
+
 func defaultAssets() map[string][]byte {
 	return map[string][]byte{
 		// schema generation template
@@ -185,6 +205,10 @@ func defaultAssets() map[string][]byte {
 		"schemaexpr.gotmpl":      []byte(schemaExprTmpl),
 		"introduction.gotmpl":    []byte(introductionTmpl),
 		"propertydoc.gotmpl":     []byte(propertyDocTmpl),
+		"gvkregistry.gotmpl":     []byte(gvkRegistryTmpl),
+		"index.gotmpl":           []byte(indexTmpl),
+		"kclmod.gotmpl":          []byte(kclModTmpl),
+		"modelsfile.gotmpl":      []byte(modelsFileTmpl),
 	}
 }
 
@@ -218,6 +242,7 @@ func defaultProtectedTemplates() map[string]bool {
 		"tuplefield":                  true,
 		"tuplefieldIface":             true,
 		"typeSchemaType":              true,
+		"typeAlias":                   true,
 		"validationCustomformat":      true,
 		"validationPrimitive":         true,
 		"validationStructfield":       true,
@@ -225,6 +250,10 @@ func defaultProtectedTemplates() map[string]bool {
 		"withoutBaseTypeBody":         true,
 		"introduction":                true,
 		"propertydoc":                 true,
+		"gvkregistry":                 true,
+		"index":                       true,
+		"kclmod":                      true,
+		"modelsfile":                  true,
 	}
 }
 
@@ -260,6 +289,38 @@ func (t *Repository) LoadDefaults() {
 	}
 }
 
+// LoadDir loads the templates of a directory and of its subdirectories over the loaded ones, e.g. a docstring.gotmpl
+// file overrides the embedded docstring template. The protected templates it overrides are logged.
+func (t *Repository) LoadDir(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".gotmpl" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		templ, err := template.New(name).Funcs(t.funcs).Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("failed to load template %s: %v", path, err)
+		}
+		for _, tmpl := range templ.Templates() {
+			if protectedTemplates[tmpl.Name()] {
+				log.Printf("the protected template %s is overridden by %s", tmpl.Name(), path)
+			}
+		}
+		return t.addFile(name, string(content), true)
+	})
+}
+
 func (t *Repository) addFile(name, data string, allowOverride bool) error {
 	fileName := name
 	name = swag.ToJSONName(strings.TrimSuffix(name, ".gotmpl"))
@@ -422,6 +483,8 @@ func (t *Repository) addDependencies(templ *template.Template) (*template.Templa
 
 // Get will return the named template from the repository, ensuring that all dependent templates are loaded.
 // It will return an error if a dependent template is not defined in the repository.
+// The dependencies are added to a clone of the template, so that the templates of the repository are never
+// modified and can be got and executed by concurrent renderings.
 func (t *Repository) Get(name string) (*template.Template, error) {
 	templ, found := t.templates[name]
 
@@ -429,7 +492,11 @@ func (t *Repository) Get(name string) (*template.Template, error) {
 		return templ, fmt.Errorf("template doesn't exist %s", name)
 	}
 
-	return t.addDependencies(templ)
+	clone, err := templ.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone template %s: %v", name, err)
+	}
+	return t.addDependencies(clone)
 }
 
 // DumpTemplates prints out a dump of all the defined templates, where they are defined and what their dependencies are.
@@ -494,6 +561,24 @@ func padSurround(entry, padWith string, i, ln int) string {
 	return strings.Join(res, ",")
 }
 
+// seeAlso renders the external documentation of a schema as a "See also: <description> (<url>)" line,
+// or an empty string when there is no external documentation
+func seeAlso(docs *spec.ExternalDocumentation) string {
+	if docs == nil {
+		return ""
+	}
+	description, url := strings.TrimSpace(docs.Description), strings.TrimSpace(docs.URL)
+	switch {
+	case description != "" && url != "":
+		return fmt.Sprintf("See also: %s (%s)", description, url)
+	case description != "":
+		return "See also: " + description
+	case url != "":
+		return "See also: " + url
+	}
+	return ""
+}
+
 // padDocument indent multi line document with given pad
 func padDocument(str string, pad string) string {
 	// get the OS name
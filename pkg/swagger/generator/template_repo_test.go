@@ -33,6 +33,11 @@ func TestToKCLValue(t *testing.T) {
 			value:  "hello",
 			expect: "\"hello\"",
 		},
+		{
+			name:   "big-int",
+			value:  int64(9007199254740993),
+			expect: "9007199254740993",
+		},
 		{
 			name: "map-string-int",
 			value: yaml.MapSlice{
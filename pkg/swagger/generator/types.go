@@ -16,9 +16,9 @@ package generator
 
 import (
 	"fmt"
-	"log"
-	"path"
+	"net/url"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/go-openapi/loads"
@@ -39,15 +39,26 @@ const (
 const (
 	intOrStr        = "intorstring"
 	k8sIntOrStrFlag = "x-kubernetes-int-or-string"
+	k8sGVK          = "x-kubernetes-group-version-kind"
+	k8sValidations  = "x-kubernetes-validations"
+	// the format of the kubernetes quantities, such as "500m" or "2Gi"
+	quantity = "quantity"
+	// the unknown fields of an object are preserved by kubernetes instead of being pruned
+	k8sPreserveUnknownFields = "x-kubernetes-preserve-unknown-fields"
 )
 
 // Extensions supported by go-swagger
 const (
-	xSchema    = "x-schema"   // schema name used by discriminator
-	xKclName   = "x-kcl-name" // name of the generated kcl variable
-	xKclType   = "x-kcl-type" // reuse existing type (do not generate)
-	xOmitEmpty = "x-omitempty"
-	xOrder     = "x-order" // sort order for properties, and "default"/"example" fields in schema
+	xSchema           = "x-schema"   // schema name used by discriminator
+	xKclName          = "x-kcl-name" // name of the generated kcl variable
+	xKclType          = "x-kcl-type" // reuse existing type (do not generate)
+	xOmitEmpty        = "x-omitempty"
+	xOrder            = "x-order"              // sort order for properties, and "default"/"example" fields in schema
+	xKclPrinterColumn = "x-kcl-printer-column" // CRD printer column displaying the property
+	xKclMixins        = "x-kcl-mixins"         // mixins extracted from the properties of the schema
+	xDeprecated       = "x-deprecated"         // deprecated schema or property
+	xDeprecatedReason = "x-deprecated-reason"  // reason of the deprecation, rendered by the @deprecated decorator
+	xExamples         = "x-examples"           // named examples of the schema, converted from the OpenAPI 3.0 "examples"
 )
 
 // swaggerTypeName contains a mapping from go type to swagger type or format
@@ -61,7 +72,7 @@ func initTypes() {
 }
 
 func newTypeResolver(pkg string, doc *loads.Document) *typeResolver {
-	resolver := typeResolver{ModelsPackage: pkg, Doc: doc}
+	resolver := typeResolver{ModelsPackage: pkg, Doc: doc, UnionMapping: unionMapping, warnedFormats: make(map[string]struct{})}
 	resolver.KnownDefs = make(map[string]struct{}, len(doc.Spec().Definitions))
 	for k, sch := range doc.Spec().Definitions {
 		tpe, _, _, _ := knownDefKclType(k, sch, nil)
@@ -92,43 +103,53 @@ func knownDefKclType(def string, schema spec.Schema, clear func(string) string)
 		debugLog("known def type clear: %q -> %q", def, clear(def))
 		return clear(def), "", "", ""
 	}
-	xt := v.(map[string]interface{})
-	t := xt["type"].(string)
+	xt, _ := v.(map[string]interface{})
+	t, _ := xt["type"].(string)
+	if t == "" {
+		debugLog("the %s extension of %s has no type, %s is used", xKclType, def, def)
+		t = def
+	}
 	var clearedTpe string
 	if clear == nil {
 		clearedTpe = t
 	} else {
 		clearedTpe = clear(t)
 	}
-	impIface, ok := xt["import"]
+	imp, ok := xt["import"].(map[string]interface{})
 	if !ok {
 		return clearedTpe, "", "", ""
 	}
-	imp := impIface.(map[string]interface{})
-	pkg := imp["package"].(string)
-	alias := ""
-	newPkg := pkg
-	// hack start
-	goodIdx := strings.LastIndex(pkg, ".")
-	if goodIdx != -1 {
-		newPkg = pkg[:goodIdx]
-	}
-	goodIdx = strings.LastIndex(newPkg, ".")
-	if goodIdx != -1 {
-		alias = newPkg[goodIdx+1:]
-	} else {
-		alias = newPkg
+	modulePath, _ := imp["package"].(string)
+	alias, _ := imp["alias"].(string)
+	pkg, pkgAlias, module := kclTypeImport(modulePath, alias)
+	debugLog("known def type %s no clear: %q: pkg=%s, alias=%s, module=%s", xKclType, t, pkg, pkgAlias, module)
+	return clearedTpe, pkg, pkgAlias, module
+}
+
+// kclTypeImport splits the import package of the x-kcl-type extension, which is the path of the module defining
+// the type, into the package the module belongs to, the name of that package and the name of the module, e.g.
+// k8s.api.core.v1.pod is the pod module of the k8s.api.core.v1 package, named v1. The name of the module is the
+// import alias when it is set. A module at the root, such as pod, is its own package.
+func kclTypeImport(modulePath, alias string) (pkg, pkgAlias, module string) {
+	var parts []string
+	for _, part := range strings.Split(modulePath, ".") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
 	}
-	// hack end
-	var module string
-	al, ok := imp["alias"]
-	if ok {
-		module = al.(string)
-	} else {
-		module = path.Base(pkg)
+	if len(parts) == 0 {
+		return "", "", ""
+	}
+	module = parts[len(parts)-1]
+	if len(parts) > 1 {
+		parts = parts[:len(parts)-1]
+	}
+	pkg = strings.Join(parts, ".")
+	pkgAlias = parts[len(parts)-1]
+	if alias = strings.TrimSpace(alias); alias != "" {
+		module = alias
 	}
-	debugLog("known def type %s no clear: %q: pkg=%s, alias=%s, module=%s", xKclType, t, newPkg, alias, module)
-	return clearedTpe, newPkg, alias, module
+	return pkg, pkgAlias, module
 }
 
 type typeResolver struct {
@@ -136,15 +157,31 @@ type typeResolver struct {
 	ModelsPackage string
 	ModelName     string
 	KnownDefs     map[string]struct{}
+	// UnionMapping maps formats and boolean vendor extensions to kcl union types
+	UnionMapping map[string]string
+	// WarnUnknownFormats reports the integer and number formats which are not mapped to any kcl type
+	WarnUnknownFormats bool
+	// QuantityTypes types the values of the quantity format with the NumberMultiplier of the units module
+	QuantityTypes bool
+	// QuantityFields are the names of the properties whose values are quantities, when QuantityTypes is set
+	QuantityFields map[string]struct{}
 	// unexported fields
 	keepDefinitionsPkg string
 	knownDefsKept      map[string]struct{}
+	warnedFormats      map[string]struct{}
+	warnings           *warningRecorder
 }
 
 // NewWithModelName clones a type resolver and specifies a new model name
 func (t *typeResolver) NewWithModelName(name string) *typeResolver {
 	tt := newTypeResolver(t.ModelsPackage, t.Doc)
 	tt.ModelName = name
+	tt.UnionMapping = t.UnionMapping
+	tt.WarnUnknownFormats = t.WarnUnknownFormats
+	tt.QuantityTypes = t.QuantityTypes
+	tt.QuantityFields = t.QuantityFields
+	tt.warnedFormats = t.warnedFormats
+	tt.warnings = t.warnings
 
 	// propagates kept definitions
 	tt.keepDefinitionsPkg = t.keepDefinitionsPkg
@@ -161,7 +198,7 @@ func (t *typeResolver) resolveSchemaRef(schema *spec.Schema, isRequired bool) (r
 	var ref *spec.Schema
 	var er error
 
-	ref, er = spec.ResolveRef(t.Doc.Spec(), &schema.Ref)
+	ref, target, er := resolveRef(t.Doc.Spec(), schema.Ref)
 	if er != nil {
 		debugLog("error resolving ref %s: %v", schema.Ref.String(), er)
 		err = er
@@ -174,7 +211,7 @@ func (t *typeResolver) resolveSchemaRef(schema *spec.Schema, isRequired bool) (r
 	}
 	result = res
 
-	tn := filepath.Base(schema.Ref.GetURL().Fragment)
+	tn := filepath.Base(target.GetURL().Fragment)
 	tpe, pkg, alias, module := knownDefKclType(tn, *ref, t.kclTypeName)
 	debugLog("type name %s, package %s, alias %s, module %s", tpe, pkg, alias, module)
 	if tpe != "" {
@@ -188,6 +225,95 @@ func (t *typeResolver) resolveSchemaRef(schema *spec.Schema, isRequired bool) (r
 	return
 }
 
+// normalizeRef trims the whitespaces around the tokens of a $ref fragment and decodes their
+// URL-encoded characters, so that hand-edited refs such as "#/definitions/ Pet%54ag" designate
+// the "PetTag" definition. Refs which can't be normalized are returned as is.
+func normalizeRef(ref spec.Ref) spec.Ref {
+	raw := strings.TrimSpace(ref.String())
+	idx := strings.Index(raw, "#")
+	if idx == -1 {
+		return ref
+	}
+	tokens := strings.Split(raw[idx+1:], "/")
+	for i, token := range tokens {
+		token = strings.TrimSpace(token)
+		if decoded, err := url.PathUnescape(token); err == nil {
+			token = strings.TrimSpace(strings.Replace(decoded, "/", "~1", -1))
+		}
+		tokens[i] = token
+	}
+	normalized, err := spec.NewRef(raw[:idx+1] + strings.Join(tokens, "/"))
+	if err != nil {
+		return ref
+	}
+	return normalized
+}
+
+// resolveRef resolves a $ref to the schema it designates, together with the ref which
+// eventually names that schema.
+//
+// Besides plain schema refs, refs to reusable parameters (#/parameters/...) and
+// responses (#/responses/...) are supported: the schema carried by the component is
+// resolved instead. When that schema is a $ref, it is followed so that the generated
+// type is named after the underlying definition. Else the inline schema is returned with
+// an empty ref, since nothing names it: it is resolved in place, unless it is an object,
+// which is never generated, so it can't be referenced.
+func resolveRef(root *spec.Swagger, ref spec.Ref) (*spec.Schema, spec.Ref, error) {
+	ref = normalizeRef(ref)
+	fragment := ref.GetURL().Fragment
+	var sch *spec.Schema
+	var component string
+	switch {
+	case strings.HasPrefix(fragment, "/parameters/"):
+		param, err := spec.ResolveParameter(root, ref)
+		if err != nil {
+			return nil, ref, err
+		}
+		sch, component = param.Schema, "parameter"
+	case strings.HasPrefix(fragment, "/responses/"):
+		resp, err := spec.ResolveResponse(root, ref)
+		if err != nil {
+			return nil, ref, err
+		}
+		sch, component = resp.Schema, "response"
+	default:
+		rsch, err := spec.ResolveRef(root, &ref)
+		return rsch, ref, err
+	}
+	if sch == nil {
+		return nil, ref, fmt.Errorf("the $ref %s points to a %s without schema", ref.String(), component)
+	}
+	if sch.Ref.String() != "" {
+		return resolveRef(root, sch.Ref)
+	}
+	if hasInlineObject(sch) {
+		return nil, ref, fmt.Errorf("the $ref %s points to a %s with an inline object schema, which is not generated: move the schema to the definitions and $ref it from the %s", ref.String(), component, component)
+	}
+	return sch, spec.Ref{}, nil
+}
+
+// hasInlineObject tells if an inline schema is an object with properties, or an array or a map of such objects,
+// whose model is only generated for a definition
+func hasInlineObject(sch *spec.Schema) bool {
+	if sch == nil || sch.Ref.String() != "" {
+		return false
+	}
+	if len(sch.Properties) > 0 || len(sch.AllOf) > 0 || sch.Discriminator != "" {
+		return true
+	}
+	if sch.Items != nil {
+		if hasInlineObject(sch.Items.Schema) {
+			return true
+		}
+		for i := range sch.Items.Schemas {
+			if hasInlineObject(&sch.Items.Schemas[i]) {
+				return true
+			}
+		}
+	}
+	return sch.AdditionalProperties != nil && hasInlineObject(sch.AdditionalProperties.Schema)
+}
+
 func (t *typeResolver) resolveFormat(schema *spec.Schema, isAnonymous, isRequired bool) (returns bool, result resolvedType, err error) {
 	if schema.Format != "" {
 		// defaults to string
@@ -198,7 +324,15 @@ func (t *typeResolver) resolveFormat(schema *spec.Schema, isAnonymous, isRequire
 
 		debugLog("resolving format (anon: %t, req: %t)", isAnonymous, isRequired)
 		schFmt := strings.Replace(schema.Format, "-", "", -1)
-		if fmm, ok := formatMapping[result.SwaggerType]; ok {
+		if t.QuantityTypes && schFmt == quantity {
+			returns = true
+			result.KclType = quantityType(schema)
+			result.IsQuantity = true
+		} else if tpe, ok := t.UnionMapping[schFmt]; ok {
+			returns = true
+			result.KclType = tpe
+		}
+		if fmm, ok := formatMapping[result.SwaggerType]; !returns && ok {
 			if tpe, ok := fmm[schFmt]; ok {
 				returns = true
 				result.KclType = tpe
@@ -208,6 +342,9 @@ func (t *typeResolver) resolveFormat(schema *spec.Schema, isAnonymous, isRequire
 			returns = true
 			result.KclType = tpe
 		}
+		if !returns && t.WarnUnknownFormats && (result.SwaggerType == integer || result.SwaggerType == number) {
+			t.warnUnknownFormat(schema.Format, result.SwaggerType)
+		}
 
 		result.SwaggerFormat = schema.Format
 		// propagate extensions in resolvedType
@@ -216,19 +353,53 @@ func (t *typeResolver) resolveFormat(schema *spec.Schema, isAnonymous, isRequire
 	return
 }
 
+// warnUnknownFormat reports once a format which is not mapped to any kcl type for an integer or number
+func (t *typeResolver) warnUnknownFormat(format, swaggerType string) {
+	if _, ok := knownNumberFormats[strings.Replace(format, "-", "", -1)]; ok {
+		return
+	}
+	key := swaggerType + "/" + format
+	if _, warned := t.warnedFormats[key]; warned {
+		return
+	}
+	t.warnedFormats[key] = struct{}{}
+	t.warnings.warn(t.ModelName, "unknown format %q of the %s type in %s, fall back to the %s type", format, swaggerType, t.ModelName, typeMapping[swaggerType])
+}
+
 func (t *typeResolver) resolveExtensions(schema *spec.Schema, isAnonymous, isRequired bool) (returns bool, result resolvedType, err error) {
-	if schema.VendorExtensible.Extensions != nil {
-		if value, ok := schema.VendorExtensible.Extensions.GetBool(k8sIntOrStrFlag); value && ok {
-			// the schema has {"x-kubernetes-int-or-string": "true"} flag
-			debugLog("resolving x-kubernetes-int-or-string type flag (anon: %t, req: %t)", isAnonymous, isRequired)
+	if schema.VendorExtensible.Extensions == nil {
+		return
+	}
+	if _, ok := schema.Extensions[xKclType]; ok && isAnonymous {
+		// the inline schema reuses an existing type, such as {"x-kcl-type": {"type": "ObjectMeta", "import": {...}}}
+		debugLog("resolving %s inline type (anon: %t, req: %t)", xKclType, isAnonymous, isRequired)
+		returns = true
+		result.SwaggerType = object
+		result.KclType, result.Pkg, result.PkgAlias, result.Module = knownDefKclType(t.ModelName, *schema, nil)
+		result.IsComplexObject = true
+		result.Extensions = schema.Extensions
+		return
+	}
+	flags := make([]string, 0, len(t.UnionMapping))
+	for k := range t.UnionMapping {
+		if strings.HasPrefix(k, "x-") {
+			flags = append(flags, k)
+		}
+	}
+	sort.Strings(flags)
+	for _, flag := range flags {
+		if value, ok := schema.VendorExtensible.Extensions.GetBool(flag); value && ok {
+			// the schema has a flag such as {"x-kubernetes-int-or-string": "true"}
+			debugLog("resolving %s type flag (anon: %t, req: %t)", flag, isAnonymous, isRequired)
 			returns = true
 			result.SwaggerType = str
 			if len(schema.Type) > 0 {
 				result.SwaggerType = schema.Type[0]
 			}
-			result.KclType = typeMapping[intOrStr]
+			result.KclType = t.UnionMapping[flag]
 			// propagate extensions in resolvedType
 			result.Extensions = schema.Extensions
+			return
 		}
 	}
 	return
@@ -241,7 +412,7 @@ func (t *typeResolver) firstType(schema *spec.Schema) string {
 	if len(schema.Type) > 1 {
 		// JSON-Schema multiple types, e.g. {"type": [ "object", "array" ]} are not supported.
 		// TODO: should keep the first _supported_ type, e.g. skip null
-		log.Printf("warning: JSON-Schema type definition as array with several types is not supported in %#v. Taking the first type: %s", schema.Type, schema.Type[0])
+		t.warnings.unsupported(t.ModelName, "JSON-Schema type definition as array with several types is not supported in %#v. Taking the first type: %s", schema.Type, schema.Type[0])
 	}
 	return schema.Type[0]
 }
@@ -283,7 +454,7 @@ func (t *typeResolver) resolveArray(schema *spec.Schema, isAnonymous, isRequired
 }
 
 func (t *typeResolver) kclTypeName(modelName string) string {
-	escapedName := DefaultLanguageFunc().MangleModelName(modelName)
+	escapedName := DefaultLanguageFunc().mangleModelName(modelName, t.warnings)
 	if len(t.knownDefsKept) > 0 {
 		// if a definitions package has been defined, already resolved definitions are
 		// always resolved against their original package (e.g. "models"), and not the
@@ -339,10 +510,14 @@ func (t *typeResolver) resolveObject(schema *spec.Schema, isAnonymous bool) (res
 			err = er
 			return
 		}
-		result.IsMap = !result.IsComplexObject
+		result.ElemType = &et
+		if result.IsComplexObject {
+			// an object with properties is a schema, its additional properties are typed by an index signature
+			return
+		}
+		result.IsMap = true
 		result.SwaggerType = object
 		result.KclType = "{str:" + et.KclType + "}"
-		result.ElemType = &et
 		return
 	}
 	if len(schema.Properties) > 0 {
@@ -365,6 +540,13 @@ func (t *typeResolver) ResolveSchema(schema *spec.Schema, isAnonymous, isRequire
 		return
 	}
 
+	if schema.Ref.String() != "" {
+		if rsch, target, er := resolveRef(t.Doc.Spec(), schema.Ref); er == nil && target.String() == "" {
+			// the inline schema of a parameter or a response is not named, it is resolved in place
+			return t.ResolveSchema(rsch, true, isRequired)
+		}
+	}
+
 	tpe := t.firstType(schema)
 	var returns bool
 	returns, result, err = t.resolveSchemaRef(schema, isRequired)
@@ -426,6 +608,8 @@ type resolvedType struct {
 	IsEmptyOmitted bool
 	IsJSONString   bool
 	IsBase64       bool
+	// A kubernetes quantity, typed with the units module
+	IsQuantity bool
 
 	// A tuple gets rendered as an anonymous struct with P{index} as property name
 	IsTuple            bool
@@ -0,0 +1,327 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-openapi/loads"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/swag"
+)
+
+func loadTestSpec(t *testing.T, content string) *loads.Document {
+	raw, err := swag.YAMLToJSON(mustYAML(t, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := loads.Analyzed(raw, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func mustYAML(t *testing.T, content string) interface{} {
+	data, err := swag.BytesToYAMLDoc([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestResolveSchemaRefToComponent(t *testing.T) {
+	doc := loadTestSpec(t, `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+parameters:
+  PetBody:
+    name: body
+    in: body
+    schema:
+      $ref: "#/definitions/Pet"
+  PetName:
+    name: name
+    in: body
+    schema:
+      type: string
+responses:
+  PetResponse:
+    description: a pet
+    schema:
+      $ref: "#/definitions/Pet"
+  Error:
+    description: an inline error
+    schema:
+      type: object
+      properties:
+        message:
+          type: string
+  Empty:
+    description: no content
+  Names:
+    description: the names of the pets
+    schema:
+      type: array
+      items:
+        type: string
+  Errors:
+    description: inline errors
+    schema:
+      type: array
+      items:
+        type: object
+        properties:
+          message:
+            type: string
+definitions:
+  Pet:
+    type: object
+    properties:
+      name:
+        type: string
+`)
+	cases := []struct {
+		ref       string
+		expect    string
+		expectErr string
+	}{
+		{ref: "#/responses/PetResponse", expect: "Pet"},
+		{ref: "#/parameters/PetBody", expect: "Pet"},
+		{ref: "#/parameters/PetName", expect: "str"},
+		{ref: "#/responses/Names", expect: "[str]"},
+		{ref: "#/responses/Error", expectErr: "the $ref #/responses/Error points to a response with an inline object schema, which is not generated"},
+		{ref: "#/responses/Errors", expectErr: "the $ref #/responses/Errors points to a response with an inline object schema, which is not generated"},
+		{ref: "#/responses/Empty", expectErr: "the $ref #/responses/Empty points to a response without schema"},
+	}
+	for _, testcase := range cases {
+		t.Run(testcase.ref, func(t *testing.T) {
+			resolver := newTypeResolver("", doc)
+			got, err := resolver.ResolveSchema(spec.RefSchema(testcase.ref), false, true)
+			if testcase.expectErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), testcase.expectErr) {
+					t.Fatalf("unexpected error resolving %s: %v", testcase.ref, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.KclType != testcase.expect {
+				t.Fatalf("unexpected kcl type, expect: %s, got: %s", testcase.expect, got.KclType)
+			}
+		})
+	}
+}
+
+func TestResolveUnionMapping(t *testing.T) {
+	doc := loadTestSpec(t, `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions: {}
+`)
+	resolver := newTypeResolver("", doc)
+	resolver.UnionMapping = newUnionMapping(map[string]string{
+		"int-or-str-or-bool": "int | str | bool",
+		"X-Int-Or-Bool":      "int | bool",
+	})
+	flagged := func(flag string) *spec.Schema {
+		sch := new(spec.Schema).Typed(str, "")
+		sch.AddExtension(flag, true)
+		return sch
+	}
+	cases := []struct {
+		name   string
+		schema *spec.Schema
+		expect string
+	}{
+		{name: "custom-format", schema: new(spec.Schema).Typed(str, "int-or-str-or-bool"), expect: "int | str | bool"},
+		{name: "custom-flag", schema: flagged("x-int-or-bool"), expect: "int | bool"},
+		{name: "int-or-string-format", schema: new(spec.Schema).Typed(str, "int-or-string"), expect: "int | str"},
+		{name: "int-or-string-flag", schema: flagged(k8sIntOrStrFlag), expect: "int | str"},
+		{name: "unmapped-format", schema: new(spec.Schema).Typed(str, "int-or-float"), expect: "str"},
+	}
+	for _, testcase := range cases {
+		t.Run(testcase.name, func(t *testing.T) {
+			got, err := resolver.ResolveSchema(testcase.schema, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.KclType != testcase.expect {
+				t.Fatalf("unexpected kcl type, expect: %s, got: %s", testcase.expect, got.KclType)
+			}
+		})
+	}
+}
+
+func TestWarnUnknownFormats(t *testing.T) {
+	doc := loadTestSpec(t, `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions: {}
+`)
+	resolver := newTypeResolver("", doc)
+	resolver.ModelName = "Pet"
+	resolver.WarnUnknownFormats = true
+	cases := []struct {
+		name   string
+		schema *spec.Schema
+		expect string
+		warn   string
+	}{
+		{name: "unknown-integer-format", schema: new(spec.Schema).Typed(integer, "port-number"), expect: "int", warn: `[WARN] unknown format "port-number" of the integer type in Pet, fall back to the int type`},
+		{name: "unknown-number-format", schema: new(spec.Schema).Typed(number, "percent"), expect: "float", warn: `[WARN] unknown format "percent" of the number type in Pet, fall back to the float type`},
+		{name: "known-integer-format", schema: new(spec.Schema).Typed(integer, "int64"), expect: "int"},
+		{name: "string-format", schema: new(spec.Schema).Typed(str, "email"), expect: "str"},
+	}
+	for _, testcase := range cases {
+		t.Run(testcase.name, func(t *testing.T) {
+			var got resolvedType
+			output := captureLog(t, func() {
+				var err error
+				got, err = resolver.ResolveSchema(testcase.schema, true, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+			})
+			if got.KclType != testcase.expect {
+				t.Fatalf("unexpected kcl type, expect: %s, got: %s", testcase.expect, got.KclType)
+			}
+			if testcase.warn == "" {
+				assertNotContains(t, output, "[WARN]")
+			} else {
+				assertContains(t, output, testcase.warn)
+			}
+		})
+	}
+}
+
+func TestResolveNormalizedRef(t *testing.T) {
+	doc := loadTestSpec(t, `
+swagger: "2.0"
+info:
+  title: kcl
+  version: v0.0.1
+paths: {}
+definitions:
+  Owner:
+    type: object
+    properties:
+      name:
+        type: string
+  PetTag:
+    type: object
+    properties:
+      value:
+        type: string
+`)
+	cases := []struct {
+		ref    string
+		expect string
+	}{
+		{ref: "#/definitions/ Owner ", expect: "Owner"},
+		{ref: " #/definitions/Owner", expect: "Owner"},
+		{ref: "#/definitions/Pet%54ag", expect: "PetTag"},
+	}
+	for _, testcase := range cases {
+		t.Run(testcase.ref, func(t *testing.T) {
+			resolver := newTypeResolver("", doc)
+			got, err := resolver.ResolveSchema(spec.RefSchema(testcase.ref), false, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.KclType != testcase.expect {
+				t.Fatalf("unexpected kcl type, expect: %s, got: %s", testcase.expect, got.KclType)
+			}
+		})
+	}
+}
+
+func TestKnownDefKclTypeImport(t *testing.T) {
+	cases := []struct {
+		name     string
+		ext      interface{}
+		tpe      string
+		pkg      string
+		pkgAlias string
+		module   string
+	}{
+		{
+			name: "no import",
+			ext:  map[string]interface{}{"type": "Foo"},
+			tpe:  "Foo",
+		},
+		{
+			name:     "single segment",
+			ext:      map[string]interface{}{"type": "Foo", "import": map[string]interface{}{"package": "foo"}},
+			tpe:      "Foo",
+			pkg:      "foo",
+			pkgAlias: "foo",
+			module:   "foo",
+		},
+		{
+			name:     "multi segment",
+			ext:      map[string]interface{}{"type": "Foo", "import": map[string]interface{}{"package": "a.b.c"}},
+			tpe:      "Foo",
+			pkg:      "a.b",
+			pkgAlias: "b",
+			module:   "c",
+		},
+		{
+			name:     "aliased",
+			ext:      map[string]interface{}{"type": "Foo", "import": map[string]interface{}{"package": "a.b.c", "alias": "abc"}},
+			tpe:      "Foo",
+			pkg:      "a.b",
+			pkgAlias: "b",
+			module:   "abc",
+		},
+		{
+			name:     "k8s",
+			ext:      map[string]interface{}{"type": "ObjectMeta", "import": map[string]interface{}{"package": "k8s.apimachinery.pkg.apis.meta.v1.object_meta", "alias": "object_meta"}},
+			tpe:      "ObjectMeta",
+			pkg:      "k8s.apimachinery.pkg.apis.meta.v1",
+			pkgAlias: "v1",
+			module:   "object_meta",
+		},
+		{
+			name:     "stray dots and spaces",
+			ext:      map[string]interface{}{"type": "Foo", "import": map[string]interface{}{"package": " a..b.c. ", "alias": " "}},
+			tpe:      "Foo",
+			pkg:      "a.b",
+			pkgAlias: "b",
+			module:   "c",
+		},
+		{
+			name: "empty package",
+			ext:  map[string]interface{}{"type": "Foo", "import": map[string]interface{}{"package": ""}},
+			tpe:  "Foo",
+		},
+		{
+			name:     "no type",
+			ext:      map[string]interface{}{"import": map[string]interface{}{"package": "a.b.c"}},
+			tpe:      "Def",
+			pkg:      "a.b",
+			pkgAlias: "b",
+			module:   "c",
+		},
+	}
+	for _, testcase := range cases {
+		t.Run(testcase.name, func(t *testing.T) {
+			schema := spec.Schema{}
+			schema.AddExtension(xKclType, testcase.ext)
+			tpe, pkg, pkgAlias, module := knownDefKclType("Def", schema, nil)
+			if tpe != testcase.tpe || pkg != testcase.pkg || pkgAlias != testcase.pkgAlias || module != testcase.module {
+				t.Fatalf("expected %q, %q, %q, %q, got %q, %q, %q, %q",
+					testcase.tpe, testcase.pkg, testcase.pkgAlias, testcase.module, tpe, pkg, pkgAlias, module)
+			}
+		})
+	}
+}
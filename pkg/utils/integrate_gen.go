@@ -189,7 +189,10 @@ func CompareDir(a string, b string) error {
 			return fmt.Errorf("open file failed when compare, file path: %s", bPath)
 		}
 		if fA.IsDir() {
-			return CompareDir(aPath, bPath)
+			if err := CompareDir(aPath, bPath); err != nil {
+				return err
+			}
+			continue
 		}
 		linesA, err := readLines(aPath)
 		if err != nil {
@@ -199,6 +202,9 @@ func CompareDir(a string, b string) error {
 		if err != nil {
 			return fmt.Errorf("failed to readlins from %s when compare files", bPath)
 		}
+		if len(linesA) != len(linesB) {
+			return fmt.Errorf("files contain different number of lines:\n%s: %v\n%s: %v", aPath, len(linesA), bPath, len(linesB))
+		}
 		for i, line := range linesA {
 			if line != linesB[i] {
 				lineNo := i + 1